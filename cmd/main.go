@@ -3,17 +3,25 @@ package main
 import (
 	"diplomIshi/internal/config"
 	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
 	"diplomIshi/internal/routes"
 	"log"
 )
 
 func main() {
 	cfg := config.LoadConfig()
+	repo := repository.NewUserRepository(cfg.DB)
 
-	err := cfg.DB.AutoMigrate(&models.User{}, &models.Progress{}, &models.Meal{}, &models.Exercise{}, &models.Point{}, &models.Achievement{}, &models.Reminder{}, &models.Post{}, &models.Comment{})
+	if err := repo.MigrateAchievementKeys(); err != nil {
+		log.Fatal("Failed to migrate achievements:", err)
+	}
+	err := cfg.DB.AutoMigrate(&models.User{}, &models.Progress{}, &models.Meal{}, &models.Exercise{}, &models.Point{}, &models.UserXP{}, &models.PointAggregate{}, &models.Follow{}, &models.Activity{}, &models.FeedEntry{}, &models.Challenge{}, &models.ChallengeParticipant{}, &models.PointRule{}, &models.Completion{}, &models.Streak{}, &models.StreakDay{}, &models.Achievement{}, &models.AchievementDefinition{}, &models.Reminder{}, &models.ReminderLog{}, &models.Post{}, &models.PostImage{}, &models.Comment{}, &models.Reaction{}, &models.Report{}, &models.ModerationAction{}, &models.Tag{}, &models.TagUse{}, &models.Mention{}, &models.Notification{}, &models.Block{}, &models.Group{}, &models.GroupMember{}, &models.Conversation{}, &models.Message{}, &models.Measurement{}, &models.ProgressPhoto{}, &models.CoachClient{}, &models.ExportJob{})
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
+	if err := repo.MigrateSearch(); err != nil {
+		log.Fatal("Failed to set up search:", err)
+	}
 
 	r := routes.SetupRoutes(cfg)
 	r.Run(":" + cfg.Port)
@@ -1,17 +1,37 @@
 package config
 
 import (
+	"crypto/rand"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
+	"diplomIshi/internal/storage"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 type Config struct {
-	DB   *gorm.DB
-	Port string
+	DB       *gorm.DB
+	Port     string
+	XPBase   int     // XP needed to go from level 1 to level 2
+	XPGrowth float64 // Each next level needs this many times more XP
+
+	CommentMaxDepth int    // How deep comment replies can be nested
+	FeedFanOut      string // "read" or "write", see services.FeedStrategy
+	EventBus        string // "local" or "postgres", see services.NewEventBus
+
+	Storage        storage.Storage // Public uploads such as post images
+	StorageDir     string          // Served under StorageURL; empty when files live in S3
+	StorageURL     string
+	MaxUploadBytes int64
+
+	PrivateStorage    storage.PrivateStorage // Progress photos, only reachable through signed URLs
+	PrivateStorageURL string                 // Route serving signed local files; unused with S3
+	PhotoURLTTL       time.Duration          // How long a signed photo URL stays valid
+	ExportTTL         time.Duration          // How long a data export can be downloaded
 }
 
 func LoadConfig() *Config {
@@ -32,13 +52,111 @@ func LoadConfig() *Config {
 			" sslmode=require"
 	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		TranslateError: true, // Unique violations become gorm.ErrDuplicatedKey
+	})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
+	xpBase, err := strconv.Atoi(os.Getenv("XP_BASE"))
+	if err != nil || xpBase <= 0 {
+		xpBase = 100
+	}
+	xpGrowth, err := strconv.ParseFloat(os.Getenv("XP_GROWTH"), 64)
+	if err != nil || xpGrowth < 1 {
+		xpGrowth = 1.5
+	}
+
+	commentMaxDepth, err := strconv.Atoi(os.Getenv("COMMENT_MAX_DEPTH"))
+	if err != nil || commentMaxDepth < 0 {
+		commentMaxDepth = 3
+	}
+
+	maxUploadMB, err := strconv.Atoi(os.Getenv("MAX_UPLOAD_MB"))
+	if err != nil || maxUploadMB <= 0 {
+		maxUploadMB = 5
+	}
+
+	storageDir := getEnv("STORAGE_DIR", "uploads")
+	storageURL := getEnv("STORAGE_URL", "/uploads")
+	var store storage.Storage
+	if os.Getenv("STORAGE_DRIVER") == "s3" {
+		store, err = storage.NewS3Storage(storage.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			UseSSL:    os.Getenv("S3_USE_SSL") != "false",
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+		})
+		storageDir = ""
+	} else {
+		store, err = storage.NewLocalStorage(storageDir, storageURL)
+	}
+	if err != nil {
+		log.Fatal("Failed to set up storage:", err)
+	}
+
+	signingKey := []byte(os.Getenv("SIGNING_KEY"))
+	if len(signingKey) == 0 {
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			log.Fatal("Failed to generate signing key:", err)
+		}
+		log.Println("SIGNING_KEY is not set, signed URLs stop working after a restart and on other instances")
+	}
+	privateStorageURL := getEnv("PRIVATE_STORAGE_URL", "/private")
+	var privateStore storage.PrivateStorage
+	if os.Getenv("STORAGE_DRIVER") == "s3" {
+		privateStore, err = storage.NewS3Storage(storage.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_PRIVATE_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			UseSSL:    os.Getenv("S3_USE_SSL") != "false",
+		})
+	} else {
+		privateStore, err = storage.NewLocalPrivateStorage(getEnv("PRIVATE_STORAGE_DIR", "private_uploads"), privateStorageURL, signingKey)
+	}
+	if err != nil {
+		log.Fatal("Failed to set up private storage:", err)
+	}
+	photoURLMinutes, err := strconv.Atoi(os.Getenv("PHOTO_URL_TTL_MINUTES"))
+	if err != nil || photoURLMinutes <= 0 {
+		photoURLMinutes = 10
+	}
+	// S3 presigned URLs are valid for at most 7 days
+	exportHours, err := strconv.Atoi(os.Getenv("EXPORT_TTL_HOURS"))
+	if err != nil || exportHours <= 0 || exportHours > 7*24 {
+		exportHours = 48
+	}
+
 	return &Config{
-		DB:   db,
-		Port: os.Getenv("PORT"),
+		DB:              db,
+		Port:            os.Getenv("PORT"),
+		XPBase:          xpBase,
+		XPGrowth:        xpGrowth,
+		CommentMaxDepth: commentMaxDepth,
+		FeedFanOut:      getEnv("FEED_FANOUT", "read"),
+		EventBus:        getEnv("EVENT_BUS", "local"),
+		Storage:         store,
+		StorageDir:      storageDir,
+		StorageURL:      storageURL,
+		MaxUploadBytes:  int64(maxUploadMB) << 20,
+
+		PrivateStorage:    privateStore,
+		PrivateStorageURL: privateStorageURL,
+		PhotoURLTTL:       time.Duration(photoURLMinutes) * time.Minute,
+		ExportTTL:         time.Duration(exportHours) * time.Hour,
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
 	}
+	return fallback
 }
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+type AdminHandler struct {
+	repo *repository.UserRepository
+}
+
+func NewAdminHandler(repo *repository.UserRepository) *AdminHandler {
+	return &AdminHandler{repo: repo}
+}
+
+func (h *AdminHandler) GetPointRules(c *gin.Context) {
+	rules, err := h.repo.GetPointRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch point rules"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// SavePointRule creates or replaces the rule for the action in the URL.
+// active is required so that leaving it out can't switch the rule off.
+func (h *AdminHandler) SavePointRule(c *gin.Context) {
+	var ruleData struct {
+		models.PointRule
+		Active *bool `json:"active" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&ruleData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule := ruleData.PointRule
+	rule.Action = c.Param("action")
+	rule.Active = *ruleData.Active
+
+	if rule.BasePoints < 0 || rule.DailyCap < 0 || rule.StreakBonus < 0 || rule.MaxStreakBonus < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Point values must not be negative"})
+		return
+	}
+	for difficulty, m := range rule.DifficultyMultipliers {
+		if m <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Multiplier for " + difficulty + " must be positive"})
+			return
+		}
+	}
+
+	existing, err := h.repo.FindPointRule(rule.Action)
+	if err == nil {
+		rule.ID = existing.ID
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch point rule"})
+		return
+	}
+
+	if err := h.repo.SavePointRule(&rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save point rule"})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
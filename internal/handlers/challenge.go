@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"diplomIshi/internal/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type ChallengeHandler struct {
+	repo         *repository.UserRepository
+	challengeSvc *services.ChallengeService
+}
+
+func NewChallengeHandler(repo *repository.UserRepository, challengeSvc *services.ChallengeService) *ChallengeHandler {
+	return &ChallengeHandler{repo: repo, challengeSvc: challengeSvc}
+}
+
+func (h *ChallengeHandler) CreateChallenge(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var challenge models.Challenge
+	if err := c.ShouldBindJSON(&challenge); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	challenge.CreatorID = userID
+
+	if err := h.challengeSvc.Validate(&challenge); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.CreateChallenge(&challenge); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
+		return
+	}
+	c.JSON(http.StatusCreated, challenge)
+}
+
+func (h *ChallengeHandler) GetChallenges(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	challenges, err := h.repo.GetChallenges(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch challenges"})
+		return
+	}
+	c.JSON(http.StatusOK, challenges)
+}
+
+func (h *ChallengeHandler) GetChallenge(c *gin.Context) {
+	challenge, ok := h.visibleChallenge(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, challenge)
+}
+
+func (h *ChallengeHandler) InviteToChallenge(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	challenge, ok := h.visibleChallenge(c)
+	if !ok {
+		return
+	}
+	if challenge.CreatorID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the creator can invite"})
+		return
+	}
+	if challenge.Status != "active" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Challenge is closed"})
+		return
+	}
+
+	var inviteData struct {
+		UserIDs []uint `json:"user_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&inviteData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	missing, err := h.repo.MissingUserIDs(inviteData.UserIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check users"})
+		return
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found", "user_ids": missing})
+		return
+	}
+
+	if err := h.repo.InviteToChallenge(challenge.ID, inviteData.UserIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invite users"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Users invited"})
+}
+
+func (h *ChallengeHandler) JoinChallenge(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	challenge, ok := h.visibleChallenge(c)
+	if !ok {
+		return
+	}
+	if challenge.Status != "active" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Challenge is closed"})
+		return
+	}
+
+	participant, err := h.repo.FindParticipant(challenge.ID, userID)
+	if err != nil {
+		// Not invited, which is only fine for open challenges
+		participant = &models.ChallengeParticipant{ChallengeID: challenge.ID, UserID: userID}
+	}
+	if participant.Status == "joined" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Already joined"})
+		return
+	}
+
+	now := time.Now()
+	participant.Status = "joined"
+	participant.JoinedAt = &now
+	if err := h.repo.SaveParticipant(participant); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join challenge"})
+		return
+	}
+	c.JSON(http.StatusOK, participant)
+}
+
+func (h *ChallengeHandler) GetStandings(c *gin.Context) {
+	challenge, ok := h.visibleChallenge(c)
+	if !ok {
+		return
+	}
+
+	standings, err := h.challengeSvc.Standings(challenge)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute standings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"challenge": challenge,
+		"standings": standings,
+	})
+}
+
+// visibleChallenge loads the challenge from the URL. Invite-only challenges are a 404 to non-participants.
+func (h *ChallengeHandler) visibleChallenge(c *gin.Context) (*models.Challenge, bool) {
+	userID := c.GetUint("user_id")
+	challengeID, err := strconv.Atoi(c.Param("challenge_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid challenge_id"})
+		return nil, false
+	}
+
+	challenge, err := h.repo.FindChallenge(uint(challengeID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
+		return nil, false
+	}
+	if challenge.Visibility != "open" {
+		if _, err := h.repo.FindParticipant(challenge.ID, userID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
+			return nil, false
+		}
+	}
+	return challenge, true
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// AddCoach gives a coach access to the user's private progress, such as their photos
+func (h *ProgressHandler) AddCoach(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var coachData struct {
+		CoachID uint `json:"coach_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&coachData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	coach, err := h.repo.FindByID(coachData.CoachID)
+	if err != nil || coach.Role != "coach" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coach not found"})
+		return
+	}
+	if coach.ID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot coach yourself"})
+		return
+	}
+
+	if err := h.repo.AddCoach(coach.ID, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add coach"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Coach added"})
+}
+
+// RemoveCoach revokes the coach's access
+func (h *ProgressHandler) RemoveCoach(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	coachID, err := strconv.Atoi(c.Param("coach_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coach_id"})
+		return
+	}
+
+	if err := h.repo.RemoveCoach(uint(coachID), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove coach"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Coach removed"})
+}
+
+// GetCoaches lists the coaches the user has given access to
+func (h *ProgressHandler) GetCoaches(c *gin.Context) {
+	h.listCoaches(c, false)
+}
+
+// GetClients lists the users who gave the coach access
+func (h *ProgressHandler) GetClients(c *gin.Context) {
+	h.listCoaches(c, true)
+}
+
+func (h *ProgressHandler) listCoaches(c *gin.Context, clients bool) {
+	users, err := h.repo.GetCoaches(c.GetUint("user_id"), clients)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch coaches"})
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
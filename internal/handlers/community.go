@@ -1,19 +1,55 @@
 package handlers
 
 import (
+	"crypto/rand"
 	"diplomIshi/internal/models"
 	"diplomIshi/internal/repository"
+	"diplomIshi/internal/services"
+	"diplomIshi/internal/storage"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"html"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxPostImages     = 4
+	postThumbnailSize = 320
+	postPageSize      = 20 // Default page, also the cap of the unpaged feed
+	maxPostPageSize   = 50
 )
 
 type CommunityHandler struct {
-	repo *repository.UserRepository
+	repo            *repository.UserRepository
+	achievementSvc  *services.AchievementService
+	feedSvc         *services.FeedService
+	moderationSvc   *services.ModerationService
+	communitySvc    *services.CommunityService
+	store           storage.Storage
+	commentMaxDepth int
+	maxUploadBytes  int64
 }
 
-func NewCommunityHandler(repo *repository.UserRepository) *CommunityHandler {
-	return &CommunityHandler{repo: repo}
+func NewCommunityHandler(repo *repository.UserRepository, achievementSvc *services.AchievementService, feedSvc *services.FeedService, moderationSvc *services.ModerationService, communitySvc *services.CommunityService, store storage.Storage, commentMaxDepth int, maxUploadBytes int64) *CommunityHandler {
+	return &CommunityHandler{
+		repo:            repo,
+		achievementSvc:  achievementSvc,
+		feedSvc:         feedSvc,
+		moderationSvc:   moderationSvc,
+		communitySvc:    communitySvc,
+		store:           store,
+		commentMaxDepth: commentMaxDepth,
+		maxUploadBytes:  maxUploadBytes,
+	}
 }
 
 func (h *CommunityHandler) CreatePost(c *gin.Context) {
@@ -24,34 +60,604 @@ func (h *CommunityHandler) CreatePost(c *gin.Context) {
 		return
 	}
 	post.UserID = userID
+	post.Images = nil // Images are attached through the upload endpoint
+	post.HiddenAt = nil
+
+	if post.GroupID != nil {
+		isMember, err := h.repo.IsGroupMember(*post.GroupID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check membership"})
+			return
+		}
+		if !isMember {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only group members can post in a group"})
+			return
+		}
+	}
+
+	verdict := h.moderationSvc.Screen(post.Title, post.Content)
+	if verdict.Action == services.FilterReject {
+		h.moderationSvc.RecordRejection(userID, "post", verdict)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": verdict.Reason})
+		return
+	}
 
 	if err := h.repo.CreatePost(&post); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create post"})
 		return
 	}
+	if verdict.Action == services.FilterFlag {
+		h.moderationSvc.FlagForReview(userID, "post", post.ID, verdict)
+	}
+	h.communitySvc.IndexPost(&post)
+	if post.GroupID == nil {
+		// Group posts stay inside the group instead of reaching followers' home feeds
+		h.feedSvc.PublishPost(&post)
+	}
+	h.achievementSvc.EvaluateAsync(userID)
 
 	c.JSON(http.StatusCreated, post)
 }
 
+func (h *CommunityHandler) UpdatePost(c *gin.Context) {
+	post, ok := h.ownPost(c)
+	if !ok {
+		return
+	}
+
+	var updateData struct {
+		Title   *string `json:"title"`
+		Content *string `json:"content"`
+	}
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if updateData.Title != nil {
+		post.Title = *updateData.Title
+	}
+	if updateData.Content != nil {
+		post.Content = *updateData.Content
+	}
+
+	verdict := h.moderationSvc.Screen(post.Title, post.Content)
+	if verdict.Action == services.FilterReject {
+		h.moderationSvc.RecordRejection(post.UserID, "post", verdict)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": verdict.Reason})
+		return
+	}
+
+	if err := h.repo.UpdatePost(post); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
+		return
+	}
+	if verdict.Action == services.FilterFlag {
+		h.moderationSvc.FlagForReview(post.UserID, "post", post.ID, verdict)
+	}
+	h.communitySvc.IndexPost(post)
+	h.fillImageURLs(post)
+	c.JSON(http.StatusOK, post)
+}
+
+// DeletePost removes the post together with its comments, reactions and images
+func (h *CommunityHandler) DeletePost(c *gin.Context) {
+	post, ok := h.ownPost(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeletePost(post); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete post"})
+		return
+	}
+	// Files go only after the rows, so a failed delete never leaves broken images behind
+	for i := range post.Images {
+		deleteImageFiles(c, h.store, &post.Images[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Post deleted"})
+}
+
+// UploadPostImage attaches an image from the multipart field "image" to the post
+func (h *CommunityHandler) UploadPostImage(c *gin.Context) {
+	post, ok := h.ownPost(c)
+	if !ok {
+		return
+	}
+	if len(post.Images) >= maxPostImages {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A post can have at most %d images", maxPostImages)})
+		return
+	}
+
+	data, ok := readUpload(c, "image", h.maxUploadBytes)
+	if !ok {
+		return
+	}
+
+	processed, err := services.ProcessImage(data, postThumbnailSize)
+	if errors.Is(err, services.ErrImageTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrUnsupportedImage) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process image"})
+		return
+	}
+
+	name, err := randomName()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		return
+	}
+	image := models.PostImage{
+		PostID:       post.ID,
+		Key:          fmt.Sprintf("posts/%d/%s%s", post.ID, name, processed.Ext),
+		ThumbnailKey: fmt.Sprintf("posts/%d/%s_thumb%s", post.ID, name, processed.Ext),
+		ContentType:  processed.ContentType,
+		Width:        processed.Width,
+		Height:       processed.Height,
+		Size:         len(processed.Data),
+	}
+
+	ctx := c.Request.Context()
+	if err := h.store.Put(ctx, image.Key, processed.Data, image.ContentType); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		return
+	}
+	if err := h.store.Put(ctx, image.ThumbnailKey, processed.Thumbnail, image.ContentType); err != nil {
+		deleteImageFiles(c, h.store, &image)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		return
+	}
+	added, err := h.repo.AddPostImage(&image, maxPostImages)
+	if err != nil {
+		deleteImageFiles(c, h.store, &image)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		return
+	}
+	if !added {
+		deleteImageFiles(c, h.store, &image)
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A post can have at most %d images", maxPostImages)})
+		return
+	}
+
+	image.URL = h.store.URL(image.Key)
+	image.ThumbnailURL = h.store.URL(image.ThumbnailKey)
+	c.JSON(http.StatusCreated, image)
+}
+
+func (h *CommunityHandler) DeletePostImage(c *gin.Context) {
+	post, ok := h.ownPost(c)
+	if !ok {
+		return
+	}
+	imageID, err := strconv.Atoi(c.Param("image_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image_id"})
+		return
+	}
+
+	image, err := h.repo.GetPostImage(post.ID, uint(imageID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	if err := h.repo.DeletePostImage(image); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
+		return
+	}
+	deleteImageFiles(c, h.store, image)
+	c.JSON(http.StatusOK, gin.H{"message": "Image deleted"})
+}
+
+// visiblePost loads the post if the user may see it; group posts are for members only
+func (h *CommunityHandler) visiblePost(c *gin.Context, postID uint) (*models.Post, bool) {
+	post, err := h.repo.GetPostByID(postID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, false
+	}
+	canView, err := h.repo.CanViewPost(c.GetUint("user_id"), post)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check membership"})
+		return nil, false
+	}
+	if !canView {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, false
+	}
+	return post, true
+}
+
+// ownPost loads the post from the URL and checks that the user wrote it
+func (h *CommunityHandler) ownPost(c *gin.Context) (*models.Post, bool) {
+	userID := c.GetUint("user_id")
+	postID, err := strconv.Atoi(c.Param("post_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post_id"})
+		return nil, false
+	}
+
+	post, err := h.repo.GetPostByID(uint(postID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, false
+	}
+	if post.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can change this post"})
+		return nil, false
+	}
+	return post, true
+}
+
+func (h *CommunityHandler) fillImageURLs(post *models.Post) {
+	for i := range post.Images {
+		post.Images[i].URL = h.store.URL(post.Images[i].Key)
+		post.Images[i].ThumbnailURL = h.store.URL(post.Images[i].ThumbnailKey)
+	}
+}
+
+// readUpload reads the image in the multipart field, refusing files over maxBytes
+func readUpload(c *gin.Context, field string, maxBytes int64) ([]byte, bool) {
+	// Leave some room for the multipart headers around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+	fileHeader, err := c.FormFile(field)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large"})
+			return nil, false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image file is required"})
+		return nil, false
+	}
+	if fileHeader.Size > maxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large"})
+		return nil, false
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read image"})
+		return nil, false
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read image"})
+		return nil, false
+	}
+	return data, true
+}
+
+// deleteImageFiles removes the image and its thumbnail, logging failures instead of failing the request
+func deleteImageFiles(c *gin.Context, store storage.Storage, image *models.PostImage) {
+	for _, key := range []string{image.Key, image.ThumbnailKey} {
+		if err := store.Delete(c.Request.Context(), key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Failed to delete file %s: %v", key, err)
+		}
+	}
+}
+
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GetPosts returns one page of the public feed (?sort=new|top|hot&cursor=&limit=).
+// The response's next_cursor fetches the following page and is empty on the last one.
+// Without cursor and limit it returns the first page as a bare array, as it did before paging.
 func (h *CommunityHandler) GetPosts(c *gin.Context) {
-	posts, err := h.repo.GetPosts()
+	if c.Query("cursor") == "" && c.Query("limit") == "" {
+		h.allPosts(c)
+		return
+	}
+	h.postsPage(c, nil)
+}
+
+func (h *CommunityHandler) allPosts(c *gin.Context) {
+	sort := c.DefaultQuery("sort", "new")
+	if sort != "new" && sort != "top" && sort != "hot" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be new, top or hot"})
+		return
+	}
+	posts, err := h.repo.GetFeedPage(models.PostCursor{Sort: sort, AsOf: time.Now()}, nil, postPageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
+		return
+	}
+	if err := h.enrichPosts(c.GetUint("user_id"), posts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
+		return
+	}
+	c.JSON(http.StatusOK, posts)
+}
+
+// GetGroupPosts returns one page of a group's feed to its members, with the same parameters as GetPosts
+func (h *CommunityHandler) GetGroupPosts(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	groupID, err := strconv.Atoi(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group_id"})
+		return
+	}
+	isMember, err := h.repo.IsGroupMember(uint(groupID), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check membership"})
+		return
+	}
+	if !isMember {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only group members can see its posts"})
+		return
+	}
+	id := uint(groupID)
+	h.postsPage(c, &id)
+}
+
+func (h *CommunityHandler) postsPage(c *gin.Context, groupID *uint) {
+	userID := c.GetUint("user_id")
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 || limit > maxPostPageSize {
+		limit = postPageSize
+	}
+
+	cursor := models.PostCursor{Sort: c.DefaultQuery("sort", "new"), AsOf: time.Now()}
+	if raw := c.Query("cursor"); raw != "" {
+		if cursor, err = decodePostCursor(raw); err != nil || cursor.Sort != c.DefaultQuery("sort", cursor.Sort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	}
+	if cursor.Sort != "new" && cursor.Sort != "top" && cursor.Sort != "hot" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be new, top or hot"})
+		return
+	}
+
+	// One extra post tells whether there is a next page
+	posts, err := h.repo.GetFeedPage(cursor, groupID, limit+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
+		return
+	}
+	nextCursor := ""
+	if len(posts) > limit {
+		posts = posts[:limit]
+		last := posts[limit-1]
+		nextCursor = encodePostCursor(models.PostCursor{
+			Sort:      cursor.Sort,
+			Score:     last.Score,
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+			AsOf:      cursor.AsOf,
+		})
+	}
+
+	if err := h.enrichPosts(userID, posts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"posts":       posts,
+		"next_cursor": nextCursor,
+	})
+}
+
+// enrichPosts fills in comment counts, images and reactions with one query each
+func (h *CommunityHandler) enrichPosts(userID uint, posts []models.FeedPost) error {
+	postIDs := make([]uint, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.ID
+	}
+	commentCounts, err := h.repo.CountComments(postIDs)
+	if err != nil {
+		return err
+	}
+	images, err := h.repo.GetPostImages(postIDs)
+	if err != nil {
+		return err
+	}
+	reactions, err := h.repo.GetReactionSummaries(userID, "post", postIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		post := &posts[i]
+		post.CommentCount = commentCounts[post.ID]
+		post.Reactions = reactions[post.ID]
+		post.Images = images[post.ID]
+		h.fillImageURLs(&post.Post)
+	}
+	return nil
+}
+
+// GetFeed returns the home feed: posts, achievements and milestones of the user and everyone they follow.
+// Pages go back in time with ?cursor= set to the previous response's next_cursor.
+func (h *CommunityHandler) GetFeed(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 || limit > maxPostPageSize {
+		limit = postPageSize
+	}
+	beforeID := 0
+	if raw := c.Query("cursor"); raw != "" {
+		if beforeID, err = strconv.Atoi(raw); err != nil || beforeID < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	}
+
+	items, err := h.feedSvc.Feed(userID, uint(beforeID), limit+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch feed"})
+		return
+	}
+	nextCursor := ""
+	if len(items) > limit {
+		items = items[:limit]
+		nextCursor = strconv.Itoa(int(items[limit-1].ID))
+	}
+
+	var postIDs []uint
+	for _, item := range items {
+		if item.Type == "post" {
+			postIDs = append(postIDs, item.RefID)
+		}
+	}
+	posts, err := h.repo.GetPostsByIDs(postIDs)
+	if err == nil {
+		err = h.enrichPosts(userID, posts)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
+		return
+	}
+	postsByID := make(map[uint]*models.FeedPost, len(posts))
+	for i := range posts {
+		postsByID[posts[i].ID] = &posts[i]
+	}
+	visible := items[:0]
+	for _, item := range items {
+		if item.Type == "post" {
+			// Hidden posts drop out of the feed
+			if item.Post = postsByID[item.RefID]; item.Post == nil {
+				continue
+			}
+		}
+		visible = append(visible, item)
+	}
+	items = visible
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":       items,
+		"next_cursor": nextCursor,
+	})
+}
+
+// Search finds posts and comments (?q=&type=posts|comments&author_id=&from=&to=&page=&limit=).
+// Dates are YYYY-MM-DD in UTC and both ends are inclusive.
+func (h *CommunityHandler) Search(c *gin.Context) {
+	filter := repository.SearchFilter{
+		Query:    strings.TrimSpace(c.Query("q")),
+		Type:     c.Query("type"),
+		ViewerID: c.GetUint("user_id"),
+	}
+	if filter.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	if filter.Type != "" && filter.Type != "posts" && filter.Type != "comments" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be posts or comments"})
+		return
+	}
+	if raw := c.Query("author_id"); raw != "" {
+		authorID, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author_id"})
+			return
+		}
+		filter.AuthorID = uint(authorID)
+	}
+	var err error
+	if raw := c.Query("from"); raw != "" {
+		if filter.From, err = time.Parse("2006-01-02", raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, use YYYY-MM-DD"})
+			return
+		}
+	}
+	if raw := c.Query("to"); raw != "" {
+		if filter.To, err = time.Parse("2006-01-02", raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, use YYYY-MM-DD"})
+			return
+		}
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+
+	page, limit := pagination(c)
+	results, err := h.repo.SearchCommunity(filter, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search"})
+		return
+	}
+	for i := range results {
+		results[i].Title = highlight(results[i].Title)
+		results[i].Snippet = highlight(results[i].Snippet)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// GetTaggedPosts lists the posts using a tag in the post or its comments, newest first (?page=&limit=)
+func (h *CommunityHandler) GetTaggedPosts(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	tag := strings.ToLower(strings.TrimPrefix(c.Param("tag"), "#"))
+	page, limit := pagination(c)
+
+	posts, err := h.repo.GetTaggedPosts(tag, userID, limit, (page-1)*limit)
+	if err == nil {
+		err = h.enrichPosts(userID, posts)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{
+		"tag":   tag,
+		"posts": posts,
+		"page":  page,
+		"limit": limit,
+	})
+}
 
-	// Optionally enrich with comment count or user details
-	type PostResponse struct {
-		models.Post
-		CommentCount int `json:"comment_count"`
+// GetTrendingTags ranks the tags used in the last ?hours= (default 24) by how many people used them
+func (h *CommunityHandler) GetTrendingTags(c *gin.Context) {
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if err != nil || hours < 1 || hours > 24*30 {
+		hours = 24
 	}
-	var response []PostResponse
-	for _, post := range posts {
-		comments, _ := h.repo.GetComments(post.ID)
-		response = append(response, PostResponse{Post: post, CommentCount: len(comments)})
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 50 {
+		limit = 10
 	}
 
-	c.JSON(http.StatusOK, response)
+	tags, err := h.repo.GetTrendingTags(time.Now().Add(-time.Duration(hours)*time.Hour), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tags"})
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+// highlight escapes user text for HTML and only then turns the match marks into <mark> tags
+func highlight(text string) string {
+	text = html.EscapeString(text)
+	text = strings.ReplaceAll(text, html.EscapeString(repository.HighlightStart), "<mark>")
+	return strings.ReplaceAll(text, html.EscapeString(repository.HighlightStop), "</mark>")
+}
+
+func encodePostCursor(cursor models.PostCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePostCursor(raw string) (models.PostCursor, error) {
+	var cursor models.PostCursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
 }
 
 func (h *CommunityHandler) CreateComment(c *gin.Context) {
@@ -65,10 +671,34 @@ func (h *CommunityHandler) CreateComment(c *gin.Context) {
 	pID, _ := strconv.Atoi(postID)
 	comment.UserID = userID
 	comment.PostID = uint(pID)
+	comment.Depth = 0
+	comment.EditedAt = nil
+	comment.DeletedAt = nil
+	comment.HiddenAt = nil
 
-	_, err := h.repo.GetPostByID(comment.PostID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+	post, ok := h.visiblePost(c, comment.PostID)
+	if !ok {
+		return
+	}
+
+	// Replies must stay in the same post and within the depth limit
+	if comment.ParentID != nil {
+		parent, err := h.repo.GetCommentByID(*comment.ParentID)
+		if err != nil || parent.PostID != comment.PostID || parent.DeletedAt != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent comment not found"})
+			return
+		}
+		if parent.Depth+1 > h.commentMaxDepth {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Reply is nested too deeply"})
+			return
+		}
+		comment.Depth = parent.Depth + 1
+	}
+
+	verdict := h.moderationSvc.Screen(comment.Content)
+	if verdict.Action == services.FilterReject {
+		h.moderationSvc.RecordRejection(userID, "comment", verdict)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": verdict.Reason})
 		return
 	}
 
@@ -76,17 +706,357 @@ func (h *CommunityHandler) CreateComment(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
 		return
 	}
+	if verdict.Action == services.FilterFlag {
+		h.moderationSvc.FlagForReview(userID, "comment", comment.ID, verdict)
+	}
+	h.communitySvc.IndexComment(&comment)
+	h.communitySvc.NotifyComment(post, &comment)
 
 	c.JSON(http.StatusCreated, comment)
 }
 
+type CommentResponse struct {
+	models.Comment
+	Reactions *models.ReactionSummary `json:"reactions"`
+	Replies   []*CommentResponse      `json:"replies,omitempty"`
+}
+
+// GetComments returns the comments of a post as a reply tree (?view=tree, default)
+// or as a flat chronological page (?view=flat&page=&limit=)
 func (h *CommunityHandler) GetComments(c *gin.Context) {
+	userID := c.GetUint("user_id")
 	postID := c.Param("post_id")
 	pID, _ := strconv.Atoi(postID)
-	comments, err := h.repo.GetComments(uint(pID))
+	view := c.DefaultQuery("view", "tree")
+	if _, ok := h.visiblePost(c, uint(pID)); !ok {
+		return
+	}
+
+	var comments []models.Comment
+	var total int64
+	var err error
+	page, limit := 1, 0
+	switch view {
+	case "tree":
+		comments, err = h.repo.GetComments(uint(pID))
+	case "flat":
+		page, err = strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+		limit, err = strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if err != nil || limit < 1 || limit > 100 {
+			limit = 20
+		}
+		comments, total, err = h.repo.GetCommentsPage(uint(pID), limit, (page-1)*limit)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "view must be tree or flat"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
 		return
 	}
-	c.JSON(http.StatusOK, comments)
+
+	commentIDs := make([]uint, len(comments))
+	for i, comment := range comments {
+		commentIDs[i] = comment.ID
+	}
+	reactions, err := h.repo.GetReactionSummaries(userID, "comment", commentIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reactions"})
+		return
+	}
+
+	nodes := make([]*CommentResponse, len(comments))
+	byID := make(map[uint]*CommentResponse, len(comments))
+	for i, comment := range comments {
+		// Hidden comments keep their place in the thread, only the author still sees the text
+		if comment.HiddenAt != nil && comment.UserID != userID {
+			comment.Content = ""
+		}
+		nodes[i] = &CommentResponse{Comment: comment, Reactions: reactions[comment.ID]}
+		byID[comment.ID] = nodes[i]
+	}
+
+	if view == "flat" {
+		c.JSON(http.StatusOK, gin.H{
+			"comments": nodes,
+			"total":    total,
+			"page":     page,
+			"limit":    limit,
+		})
+		return
+	}
+
+	// Comments are ordered by creation, so a parent is always seen before its replies
+	roots := []*CommentResponse{}
+	for _, node := range nodes {
+		if node.ParentID != nil {
+			if parent, ok := byID[*node.ParentID]; ok {
+				parent.Replies = append(parent.Replies, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	c.JSON(http.StatusOK, roots)
+}
+
+func (h *CommunityHandler) UpdateComment(c *gin.Context) {
+	comment, ok := h.ownComment(c)
+	if !ok {
+		return
+	}
+	if comment.DeletedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment was deleted"})
+		return
+	}
+
+	var updateData struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	verdict := h.moderationSvc.Screen(updateData.Content)
+	if verdict.Action == services.FilterReject {
+		h.moderationSvc.RecordRejection(comment.UserID, "comment", verdict)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": verdict.Reason})
+		return
+	}
+
+	now := time.Now()
+	comment.Content = updateData.Content
+	comment.EditedAt = &now
+	if err := h.repo.UpdateComment(comment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+	if verdict.Action == services.FilterFlag {
+		h.moderationSvc.FlagForReview(comment.UserID, "comment", comment.ID, verdict)
+	}
+	h.communitySvc.IndexComment(comment)
+	c.JSON(http.StatusOK, comment)
+}
+
+// DeleteComment soft-deletes the comment: its content is removed but replies stay attached
+func (h *CommunityHandler) DeleteComment(c *gin.Context) {
+	comment, ok := h.ownComment(c)
+	if !ok {
+		return
+	}
+	if comment.DeletedAt != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Comment deleted"})
+		return
+	}
+
+	now := time.Now()
+	comment.Content = ""
+	comment.DeletedAt = &now
+	if err := h.repo.UpdateComment(comment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+	if err := h.repo.DeleteTagUses("comment", comment.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment tags"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted"})
+}
+
+// ownComment loads the comment from the URL and checks that the user wrote it
+func (h *CommunityHandler) ownComment(c *gin.Context) (*models.Comment, bool) {
+	userID := c.GetUint("user_id")
+	commentID, err := strconv.Atoi(c.Param("comment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment_id"})
+		return nil, false
+	}
+
+	comment, err := h.repo.GetCommentByID(uint(commentID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, false
+	}
+	if comment.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can change this comment"})
+		return nil, false
+	}
+	return comment, true
+}
+
+var reactionTypes = map[string]bool{"like": true, "fire": true, "strong": true, "clap": true}
+
+func (h *CommunityHandler) ReactToPost(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("post_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post_id"})
+		return
+	}
+	post, ok := h.visiblePost(c, uint(postID))
+	if !ok {
+		return
+	}
+	h.toggleReaction(c, "post", uint(postID), post.UserID)
+}
+
+func (h *CommunityHandler) ReactToComment(c *gin.Context) {
+	commentID, err := strconv.Atoi(c.Param("comment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment_id"})
+		return
+	}
+	comment, err := h.repo.GetCommentByID(uint(commentID))
+	if err != nil || comment.DeletedAt != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	if _, ok := h.visiblePost(c, comment.PostID); !ok {
+		return
+	}
+	h.toggleReaction(c, "comment", uint(commentID), comment.UserID)
+}
+
+// toggleReaction adds, switches or removes the user's reaction and returns the new counts
+func (h *CommunityHandler) toggleReaction(c *gin.Context, targetType string, targetID, authorID uint) {
+	userID := c.GetUint("user_id")
+	var reactionData struct {
+		Type string `json:"type" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&reactionData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !reactionTypes[reactionData.Type] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown reaction type"})
+		return
+	}
+
+	reaction, err := h.repo.ToggleReaction(userID, targetType, targetID, reactionData.Type)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save reaction"})
+		return
+	}
+	if reaction != nil {
+		h.communitySvc.NotifyReaction(authorID, reaction)
+	}
+
+	summaries, err := h.repo.GetReactionSummaries(userID, targetType, []uint{targetID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reactions"})
+		return
+	}
+	c.JSON(http.StatusOK, summaries[targetID])
+}
+
+func (h *CommunityHandler) FollowUser(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	targetID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		return
+	}
+	if uint(targetID) == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot follow yourself"})
+		return
+	}
+
+	if _, err := h.repo.FindByID(uint(targetID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := h.repo.Follow(userID, uint(targetID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow user"})
+		return
+	}
+	if err := h.feedSvc.Followed(userID, uint(targetID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update feed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Following"})
+}
+
+func (h *CommunityHandler) UnfollowUser(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	targetID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		return
+	}
+
+	if err := h.repo.Unfollow(userID, uint(targetID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unfollow user"})
+		return
+	}
+	if err := h.feedSvc.Unfollowed(userID, uint(targetID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update feed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Unfollowed"})
+}
+
+func (h *CommunityHandler) GetFollowers(c *gin.Context) {
+	h.listFollows(c, true)
+}
+
+func (h *CommunityHandler) GetFollowing(c *gin.Context) {
+	h.listFollows(c, false)
+}
+
+// listFollows lists who follows the user from the URL, or whom that user follows
+func (h *CommunityHandler) listFollows(c *gin.Context, followers bool) {
+	targetID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		return
+	}
+
+	users, err := h.repo.GetFollows(uint(targetID), followers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+func (h *CommunityHandler) BlockUser(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	targetID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		return
+	}
+	if uint(targetID) == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot block yourself"})
+		return
+	}
+	if _, err := h.repo.FindByID(uint(targetID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := h.repo.Block(userID, uint(targetID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User blocked"})
+}
+
+func (h *CommunityHandler) UnblockUser(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	targetID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		return
+	}
+
+	if err := h.repo.Unblock(userID, uint(targetID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked"})
 }
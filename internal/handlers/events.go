@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"diplomIshi/internal/repository"
+	"diplomIshi/internal/services"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"time"
+)
+
+// How often an idle stream gets a ping, so proxies don't close it
+const streamPingInterval = 25 * time.Second
+
+type EventHandler struct {
+	repo   *repository.UserRepository
+	events services.EventBus
+}
+
+func NewEventHandler(repo *repository.UserRepository, events services.EventBus) *EventHandler {
+	return &EventHandler{repo: repo, events: events}
+}
+
+// CreateTicket issues a short-lived ticket for opening the event stream (GET /events?ticket=)
+func (h *EventHandler) CreateTicket(c *gin.Context) {
+	ticket, expiresAt, err := newStreamTicket(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create ticket"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"ticket": ticket, "expires_at": expiresAt})
+}
+
+// Stream pushes the user's events as server-sent events until the client disconnects.
+// It starts with an "unread" event carrying the unread message count.
+func (h *EventHandler) Stream(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	events, unsubscribe := h.events.Subscribe(userID)
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // Keeps nginx from buffering the stream
+	if unread, err := h.repo.CountUnreadMessages(userID); err == nil {
+		c.SSEvent("unread", gin.H{"messages": unread})
+	}
+	// Send the headers and the first event now rather than with the first pushed event
+	c.Writer.Flush()
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event := <-events:
+			c.SSEvent(event.Type, event.Data)
+		case <-ping.C:
+			c.SSEvent("ping", time.Now().Unix())
+		}
+		return true
+	})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"diplomIshi/internal/repository"
+	"diplomIshi/internal/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type ExportHandler struct {
+	repo      *repository.UserRepository
+	exportSvc *services.ExportService
+}
+
+func NewExportHandler(repo *repository.UserRepository, exportSvc *services.ExportService) *ExportHandler {
+	return &ExportHandler{repo: repo, exportSvc: exportSvc}
+}
+
+// Export returns the user's data export. It is queued on the first call (202) and carries a signed
+// download link once it is ready; a new one can be requested after it expires.
+func (h *ExportHandler) Export(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	h.respond(c, userID, userID)
+}
+
+// ExportUser is Export for an admin answering a user's subject access request
+func (h *ExportHandler) ExportUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		return
+	}
+	if _, err := h.repo.FindByID(uint(userID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	h.respond(c, uint(userID), c.GetUint("user_id"))
+}
+
+func (h *ExportHandler) respond(c *gin.Context, userID, requestedBy uint) {
+	job, created, err := h.exportSvc.Request(userID, requestedBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request export"})
+		return
+	}
+	if err := h.exportSvc.Sign(c.Request.Context(), job); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign download link"})
+		return
+	}
+	if created || job.Status != "ready" {
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"diplomIshi/internal/services"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type GroupHandler struct {
+	repo   *repository.UserRepository
+	events services.EventBus
+}
+
+func NewGroupHandler(repo *repository.UserRepository, events services.EventBus) *GroupHandler {
+	return &GroupHandler{repo: repo, events: events}
+}
+
+func validMembership(membership string) bool {
+	return membership == "open" || membership == "request" || membership == "invite"
+}
+
+func (h *GroupHandler) CreateGroup(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var group models.Group
+	if err := c.ShouldBindJSON(&group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if group.Membership == "" {
+		group.Membership = "open"
+	}
+	if !validMembership(group.Membership) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "membership must be open, request or invite"})
+		return
+	}
+	group.ID = 0
+	group.CreatorID = userID
+
+	if err := h.repo.CreateGroup(&group); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create group"})
+		return
+	}
+	c.JSON(http.StatusCreated, group)
+}
+
+// GetGroups lists the groups the user can find (?mine=true for the groups they have joined)
+func (h *GroupHandler) GetGroups(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	groups, err := h.repo.GetGroups(userID, c.Query("mine") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch groups"})
+		return
+	}
+	c.JSON(http.StatusOK, groups)
+}
+
+func (h *GroupHandler) GetGroup(c *gin.Context) {
+	group, member, ok := h.visibleGroup(c)
+	if !ok {
+		return
+	}
+	memberIDs, err := h.repo.GroupMemberIDs(group.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch group"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"group":   group,
+		"members": len(memberIDs),
+		"me":      member,
+	})
+}
+
+func (h *GroupHandler) UpdateGroup(c *gin.Context) {
+	group, ok := h.adminGroup(c)
+	if !ok {
+		return
+	}
+	var groupData struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+		Membership  string `json:"membership" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&groupData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validMembership(groupData.Membership) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "membership must be open, request or invite"})
+		return
+	}
+
+	group.Name = groupData.Name
+	group.Description = groupData.Description
+	group.Membership = groupData.Membership
+	if err := h.repo.UpdateGroup(group); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// JoinGroup joins an open group, asks to join a request group or accepts an invitation
+func (h *GroupHandler) JoinGroup(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	group, member, ok := h.visibleGroup(c)
+	if !ok {
+		return
+	}
+	if member == nil {
+		member = &models.GroupMember{GroupID: group.ID, UserID: userID, Role: "member"}
+	}
+
+	switch {
+	case member.Status == "member":
+		c.JSON(http.StatusConflict, gin.H{"error": "Already a member"})
+		return
+	case member.Status == "requested":
+		c.JSON(http.StatusConflict, gin.H{"error": "Request already sent"})
+		return
+	case member.Status == "invited" || group.Membership == "open":
+		now := time.Now()
+		member.Status = "member"
+		member.JoinedAt = &now
+	case group.Membership == "request":
+		member.Status = "requested"
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": "This group is invite-only"})
+		return
+	}
+
+	if err := h.repo.SaveGroupMember(member); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join group"})
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+// LeaveGroup leaves the group, withdraws a join request or declines an invitation
+func (h *GroupHandler) LeaveGroup(c *gin.Context) {
+	_, member, ok := h.visibleGroup(c)
+	if !ok {
+		return
+	}
+	if member == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not a member"})
+		return
+	}
+	if !h.canRemove(c, member) {
+		return
+	}
+
+	if err := h.repo.DeleteGroupMember(member); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave group"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Left group"})
+}
+
+// GetGroupMembers lists the members (?status=member|requested|invited). Pending rows are shown to admins only.
+func (h *GroupHandler) GetGroupMembers(c *gin.Context) {
+	group, member, ok := h.visibleGroup(c)
+	if !ok {
+		return
+	}
+	if member == nil || member.Status != "member" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only group members can see the member list"})
+		return
+	}
+	status := c.DefaultQuery("status", "member")
+	if status != "member" && status != "requested" && status != "invited" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be member, requested or invited"})
+		return
+	}
+	if status != "member" && member.Role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only group admins can see pending members"})
+		return
+	}
+
+	members, err := h.repo.GetGroupMembers(group.ID, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+// InviteToGroup invites users to the group. Users who asked to join are let in right away.
+func (h *GroupHandler) InviteToGroup(c *gin.Context) {
+	group, ok := h.adminGroup(c)
+	if !ok {
+		return
+	}
+	var inviteData struct {
+		UserIDs []uint `json:"user_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&inviteData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	missing, err := h.repo.MissingUserIDs(inviteData.UserIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check users"})
+		return
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found", "user_ids": missing})
+		return
+	}
+
+	text := fmt.Sprintf("You were invited to join %s", group.Name)
+	notifications, err := h.repo.InviteToGroup(group.ID, c.GetUint("user_id"), inviteData.UserIDs, text)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invite users"})
+		return
+	}
+	for _, notification := range notifications {
+		h.events.Publish(notification.UserID, services.Event{Type: "group_invite", Data: notification})
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Users invited"})
+}
+
+// UpdateGroupMember approves a join request ({"status":"member"}) or changes a member's role ({"role":"admin"})
+func (h *GroupHandler) UpdateGroupMember(c *gin.Context) {
+	group, ok := h.adminGroup(c)
+	if !ok {
+		return
+	}
+	member, ok := h.targetMember(c, group.ID)
+	if !ok {
+		return
+	}
+	var memberData struct {
+		Status string `json:"status"`
+		Role   string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&memberData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if memberData.Status != "" {
+		if memberData.Status != "member" || member.Status != "requested" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only join requests can be approved"})
+			return
+		}
+		now := time.Now()
+		member.Status = "member"
+		member.JoinedAt = &now
+	}
+	if memberData.Role != "" {
+		if memberData.Role != "admin" && memberData.Role != "member" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role must be admin or member"})
+			return
+		}
+		if member.Status != "member" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only members can get a role"})
+			return
+		}
+		if memberData.Role == "member" && !h.canRemove(c, member) {
+			return
+		}
+		member.Role = memberData.Role
+	}
+
+	if err := h.repo.SaveGroupMember(member); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveGroupMember removes a member, rejects a join request or withdraws an invitation
+func (h *GroupHandler) RemoveGroupMember(c *gin.Context) {
+	group, ok := h.adminGroup(c)
+	if !ok {
+		return
+	}
+	member, ok := h.targetMember(c, group.ID)
+	if !ok {
+		return
+	}
+	if !h.canRemove(c, member) {
+		return
+	}
+
+	if err := h.repo.DeleteGroupMember(member); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// GetGroupRankings ranks groups by their members' points (?period=week|month|all&page=&limit=)
+func (h *GroupHandler) GetGroupRankings(c *gin.Context) {
+	period := c.DefaultQuery("period", "week")
+	page, limit := pagination(c)
+	start, err := repository.PeriodStart(period, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rankings, total, err := h.repo.GetGroupRankings(c.GetUint("user_id"), period, start, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch group leaderboard"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"period":       period,
+		"period_start": start,
+		"entries":      rankings,
+		"total":        total,
+		"page":         page,
+		"limit":        limit,
+	})
+}
+
+// visibleGroup loads the group from the URL together with the user's membership row (nil if there is none).
+// Invite-only groups stay hidden from users who were not invited.
+func (h *GroupHandler) visibleGroup(c *gin.Context) (*models.Group, *models.GroupMember, bool) {
+	userID := c.GetUint("user_id")
+	groupID, err := strconv.Atoi(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group_id"})
+		return nil, nil, false
+	}
+
+	group, err := h.repo.FindGroup(uint(groupID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return nil, nil, false
+	}
+	member, err := h.repo.FindGroupMember(group.ID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		member = nil
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check membership"})
+		return nil, nil, false
+	}
+	if member == nil && group.Membership == "invite" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return nil, nil, false
+	}
+	return group, member, true
+}
+
+// adminGroup loads the group from the URL if the user is one of its admins
+func (h *GroupHandler) adminGroup(c *gin.Context) (*models.Group, bool) {
+	group, member, ok := h.visibleGroup(c)
+	if !ok {
+		return nil, false
+	}
+	if member == nil || member.Status != "member" || member.Role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only group admins can do this"})
+		return nil, false
+	}
+	return group, true
+}
+
+// targetMember loads the membership row of the user in the URL
+func (h *GroupHandler) targetMember(c *gin.Context, groupID uint) (*models.GroupMember, bool) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		return nil, false
+	}
+	member, err := h.repo.FindGroupMember(groupID, uint(userID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return nil, false
+	}
+	return member, true
+}
+
+// canRemove keeps every group with at least one admin: the last admin cannot leave, be removed or step down
+func (h *GroupHandler) canRemove(c *gin.Context, member *models.GroupMember) bool {
+	if member.Role != "admin" || member.Status != "member" {
+		return true
+	}
+	admins, err := h.repo.CountGroupAdmins(member.GroupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check admins"})
+		return false
+	}
+	if admins <= 1 {
+		c.JSON(http.StatusConflict, gin.H{"error": "The group needs another admin first"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GetInbox lists the user's notifications, newest first, with the unread count (?page=&limit=)
+func (h *UserHandler) GetInbox(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	page, limit := pagination(c)
+
+	notifications, err := h.repo.GetNotifications(userID, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+	unread, err := h.repo.CountUnreadNotifications(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"unread_count":  unread,
+		"page":          page,
+		"limit":         limit,
+	})
+}
+
+// MarkInboxRead marks the notifications in "ids" as read, or all of them if "ids" is empty
+func (h *UserHandler) MarkInboxRead(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var readData struct {
+		IDs []uint `json:"ids"`
+	}
+	if err := c.ShouldBindJSON(&readData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.MarkNotificationsRead(userID, readData.IDs...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Notifications marked as read"})
+}
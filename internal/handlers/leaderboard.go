@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"diplomIshi/internal/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// GetLeaderboard ranks users (?period=week|month|all&scope=global|friends|group&group_id=&page=&limit=)
+func (h *UserHandler) GetLeaderboard(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	period := c.DefaultQuery("period", "week")
+	scope := c.DefaultQuery("scope", "global")
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var groupID uint
+	if scope == "group" {
+		id, err := strconv.Atoi(c.Query("group_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group_id"})
+			return
+		}
+		groupID = uint(id)
+	}
+
+	leaderboard, err := h.leaderboardSvc.Get(userID, period, scope, groupID, page, limit)
+	if errors.Is(err, services.ErrUnknownPeriod) || errors.Is(err, services.ErrUnknownScope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrNotInGroup) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only group members can see its leaderboard"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leaderboard"})
+		return
+	}
+	c.JSON(http.StatusOK, leaderboard)
+}
+
+func (h *UserHandler) SetLeaderboardOptOut(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var optOutData struct {
+		OptOut bool `json:"opt_out"`
+	}
+	if err := c.ShouldBindJSON(&optOutData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.SetLeaderboardOptOut(userID, optOutData.OptOut); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update leaderboard settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"leaderboard_opt_out": optOutData.OptOut})
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"diplomIshi/internal/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type MessageHandler struct {
+	repo       *repository.UserRepository
+	messageSvc *services.MessageService
+}
+
+func NewMessageHandler(repo *repository.UserRepository, messageSvc *services.MessageService) *MessageHandler {
+	return &MessageHandler{repo: repo, messageSvc: messageSvc}
+}
+
+func (h *MessageHandler) SendMessage(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var messageData struct {
+		RecipientID uint   `json:"recipient_id" binding:"required"`
+		Content     string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&messageData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	message, err := h.messageSvc.Send(userID, messageData.RecipientID, messageData.Content)
+	if errors.Is(err, services.ErrInvalidMessage) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrCannotMessage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+		return
+	}
+	c.JSON(http.StatusCreated, message)
+}
+
+// GetConversations lists the user's conversations with their unread counts (?page=&limit=)
+func (h *MessageHandler) GetConversations(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	page, limit := pagination(c)
+
+	conversations, err := h.repo.GetConversations(userID, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"conversations": conversations,
+		"page":          page,
+		"limit":         limit,
+	})
+}
+
+// GetMessages returns the conversation's messages, newest first (?before=&limit=).
+// The response's next_before fetches older messages and is 0 when there are none.
+func (h *MessageHandler) GetMessages(c *gin.Context) {
+	conversation, ok := h.ownConversation(c)
+	if !ok {
+		return
+	}
+	_, limit := pagination(c)
+	before, err := strconv.Atoi(c.DefaultQuery("before", "0"))
+	if err != nil || before < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before"})
+		return
+	}
+
+	messages, err := h.repo.GetMessages(conversation.ID, uint(before), limit+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
+	}
+	var nextBefore uint
+	if len(messages) > limit {
+		messages = messages[:limit]
+		nextBefore = messages[limit-1].ID
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"conversation": conversation,
+		"messages":     messages,
+		"next_before":  nextBefore,
+	})
+}
+
+// MarkConversationRead sends read receipts for every unread message of the conversation
+func (h *MessageHandler) MarkConversationRead(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	conversation, ok := h.ownConversation(c)
+	if !ok {
+		return
+	}
+
+	count, err := h.messageSvc.MarkRead(conversation, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark messages as read"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"read": count})
+}
+
+func (h *MessageHandler) GetUnreadCount(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	count, err := h.repo.CountUnreadMessages(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count messages"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"unread_count": count})
+}
+
+// ownConversation loads the conversation from the URL if the user takes part in it
+func (h *MessageHandler) ownConversation(c *gin.Context) (*models.Conversation, bool) {
+	userID := c.GetUint("user_id")
+	conversationID, err := strconv.Atoi(c.Param("conversation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation_id"})
+		return nil, false
+	}
+
+	conversation, err := h.repo.FindConversation(uint(conversationID))
+	if err != nil || (conversation.UserLowID != userID && conversation.UserHighID != userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return nil, false
+	}
+	return conversation, true
+}
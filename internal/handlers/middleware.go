@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"diplomIshi/internal/repository"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	"net/http"
 	"strings"
+	"time"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
@@ -32,8 +34,102 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		// Stream tickets only open event streams
+		if _, isTicket := claims["purpose"]; isTicket {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
 
 		c.Set("user_id", uint(claims["user_id"].(float64)))
 		c.Next()
 	}
 }
+
+// Stream tickets stand in for the login token on event streams. Browsers' EventSource cannot set headers,
+// and a ticket in the URL can end up in logs, so it only opens a stream and expires quickly.
+const (
+	streamTicketPurpose = "event_stream"
+	streamTicketTTL     = 60 * time.Second
+)
+
+func newStreamTicket(userID uint) (string, time.Time, error) {
+	expiresAt := time.Now().Add(streamTicketTTL)
+	ticket := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"purpose": streamTicketPurpose,
+		"exp":     expiresAt.Unix(),
+	})
+	signed, err := ticket.SignedString([]byte("your-secret-key"))
+	return signed, expiresAt, err
+}
+
+// StreamAuthMiddleware authenticates event streams by the ?ticket= from POST /events/ticket.
+// Login tokens are not accepted here.
+func StreamAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := jwt.Parse(c.Query("ticket"), func(token *jwt.Token) (interface{}, error) {
+			return []byte("your-secret-key"), nil
+		})
+		if err != nil || !token.Valid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired ticket"})
+			c.Abort()
+			return
+		}
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok || claims["purpose"] != streamTicketPurpose {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired ticket"})
+			c.Abort()
+			return
+		}
+		userID, ok := claims["user_id"].(float64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired ticket"})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", uint(userID))
+		c.Next()
+	}
+}
+
+// RequireRole only lets users with one of the given roles through. Must run after AuthMiddleware.
+func RequireRole(repo *repository.UserRepository, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := repo.FindByID(c.GetUint("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+
+		for _, role := range roles {
+			if user.Role == role {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
+
+// RequireNotSuspended blocks suspended users from community writes. Must run after AuthMiddleware.
+func RequireNotSuspended(repo *repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := repo.FindByID(c.GetUint("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+		if user.SuspendedUntil.After(time.Now()) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is suspended", "suspended_until": user.SuspendedUntil})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"diplomIshi/internal/services"
+	"diplomIshi/internal/storage"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+)
+
+type ModerationHandler struct {
+	repo          *repository.UserRepository
+	moderationSvc *services.ModerationService
+	store         storage.Storage
+}
+
+func NewModerationHandler(repo *repository.UserRepository, moderationSvc *services.ModerationService, store storage.Storage) *ModerationHandler {
+	return &ModerationHandler{repo: repo, moderationSvc: moderationSvc, store: store}
+}
+
+func (h *ModerationHandler) ReportPost(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("post_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post_id"})
+		return
+	}
+	post, err := h.repo.GetPostByID(uint(postID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	if canView, err := h.repo.CanViewPost(c.GetUint("user_id"), post); err != nil || !canView {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	h.report(c, "post", uint(postID))
+}
+
+func (h *ModerationHandler) ReportComment(c *gin.Context) {
+	commentID, err := strconv.Atoi(c.Param("comment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment_id"})
+		return
+	}
+	comment, err := h.repo.GetCommentByID(uint(commentID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	post, err := h.repo.GetPostByID(comment.PostID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	if canView, err := h.repo.CanViewPost(c.GetUint("user_id"), post); err != nil || !canView {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	h.report(c, "comment", uint(commentID))
+}
+
+func (h *ModerationHandler) report(c *gin.Context, targetType string, targetID uint) {
+	userID := c.GetUint("user_id")
+	var reportData struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&reportData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report := models.Report{ReporterID: &userID, TargetType: targetType, TargetID: targetID, Reason: reportData.Reason}
+	if err := h.repo.CreateReport(&report); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Already reported"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save report"})
+		return
+	}
+	c.JSON(http.StatusCreated, report)
+}
+
+// GetQueue lists reported content awaiting review, most reported first (?page=&limit=)
+func (h *ModerationHandler) GetQueue(c *gin.Context) {
+	page, limit := pagination(c)
+	items, err := h.repo.GetReportQueue(limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reports"})
+		return
+	}
+
+	for i := range items {
+		item := &items[i]
+		if item.Reports, err = h.repo.GetOpenReports(item.TargetType, item.TargetID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reports"})
+			return
+		}
+		// The target may be gone already; the moderator can still dismiss the reports
+		if item.TargetType == "post" {
+			if post, err := h.repo.GetPostByID(item.TargetID); err == nil {
+				item.Post = post
+			}
+		} else if comment, err := h.repo.GetCommentByID(item.TargetID); err == nil {
+			item.Comment = comment
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"items": items,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// Moderate applies hide, unhide, delete, warn, suspend or dismiss to a post, comment or user
+func (h *ModerationHandler) Moderate(c *gin.Context) {
+	moderatorID := c.GetUint("user_id")
+	var req services.ModerationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Remember the images, their files go once the post is deleted
+	var images []models.PostImage
+	if req.Action == "delete" && req.TargetType == "post" {
+		if post, err := h.repo.GetPostByID(req.TargetID); err == nil {
+			images = post.Images
+		}
+	}
+
+	action, err := h.moderationSvc.Moderate(moderatorID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidModeration):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrModerationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Target not found"})
+		case errors.Is(err, services.ErrModerationDenied):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can warn or suspend moderators and admins"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply moderation action"})
+		}
+		return
+	}
+	for i := range images {
+		deleteImageFiles(c, h.store, &images[i])
+	}
+	c.JSON(http.StatusOK, action)
+}
+
+// GetAuditLog lists moderation actions, newest first (?target_type=&target_id=&moderator_id=&page=&limit=)
+func (h *ModerationHandler) GetAuditLog(c *gin.Context) {
+	page, limit := pagination(c)
+	targetType := c.Query("target_type")
+	targetID, _ := strconv.Atoi(c.Query("target_id"))
+	moderatorID, _ := strconv.Atoi(c.Query("moderator_id"))
+
+	actions, err := h.repo.GetModerationActions(targetType, uint(targetID), uint(moderatorID), limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"actions": actions,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// GetMyWarnings shows the user the warnings and suspensions they received
+func (h *ModerationHandler) GetMyWarnings(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	warnings, err := h.repo.GetWarnings(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch warnings"})
+		return
+	}
+	c.JSON(http.StatusOK, warnings)
+}
+
+// SetRole lets admins appoint or remove moderators
+func (h *ModerationHandler) SetRole(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		return
+	}
+	var roleData struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&roleData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if roleData.Role != "user" && roleData.Role != "coach" && roleData.Role != "moderator" && roleData.Role != "admin" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be user, coach, moderator or admin"})
+		return
+	}
+	if uint(userID) == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot change your own role"})
+		return
+	}
+	if _, err := h.repo.FindByID(uint(userID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	audit := models.ModerationAction{
+		ModeratorID: &adminID,
+		UserID:      uint(userID),
+		Action:      "set_role",
+		TargetType:  "user",
+		TargetID:    uint(userID),
+		Reason:      "Role set to " + roleData.Role,
+	}
+	if err := h.repo.SetRole(uint(userID), roleData.Role, &audit); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated"})
+}
+
+// pagination reads ?page= and ?limit= with defaults of 1 and 20
+func pagination(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+	return page, limit
+}
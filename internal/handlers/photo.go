@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/services"
+	"diplomIshi/internal/storage"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UploadPhoto stores a private progress photo from the multipart field "photo"
+// with the form fields date (YYYY-MM-DD, defaults to today), pose and note
+func (h *ProgressHandler) UploadPhoto(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	data, ok := readUpload(c, "photo", h.maxUploadBytes)
+	if !ok {
+		return
+	}
+
+	photo := models.ProgressPhoto{
+		UserID: userID,
+		Pose:   c.DefaultPostForm("pose", "front"),
+		Note:   c.PostForm("note"),
+	}
+	if raw := c.PostForm("date"); raw != "" {
+		date, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, use YYYY-MM-DD"})
+			return
+		}
+		photo.Date = date
+	}
+
+	err := h.photoSvc.Upload(c.Request.Context(), &photo, data)
+	if errors.Is(err, services.ErrImageTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrUnknownPose) || errors.Is(err, services.ErrUnsupportedImage) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save photo"})
+		return
+	}
+	c.JSON(http.StatusCreated, photo)
+}
+
+// GetPhotos lists the user's progress photos with signed URLs, to the user and their coaches (?pose=)
+func (h *ProgressHandler) GetPhotos(c *gin.Context) {
+	ownerID, ok := h.viewableOwner(c)
+	if !ok {
+		return
+	}
+
+	photos, err := h.repo.GetProgressPhotos(ownerID, c.Query("pose"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photos"})
+		return
+	}
+	for i := range photos {
+		if err := h.photoSvc.Sign(c.Request.Context(), &photos[i], c.GetUint("user_id")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign photo URLs"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, photos)
+}
+
+// ComparePhotos pairs before/after photos per pose (?pose=&before=YYYY-MM-DD&after=YYYY-MM-DD).
+// Without dates it compares the first photo of each pose with the latest one.
+func (h *ProgressHandler) ComparePhotos(c *gin.Context) {
+	ownerID, ok := h.viewableOwner(c)
+	if !ok {
+		return
+	}
+	before, err := parseDateQuery(c, "before", time.Time{})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before date, use YYYY-MM-DD"})
+		return
+	}
+	after, err := parseDateQuery(c, "after", time.Time{})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid after date, use YYYY-MM-DD"})
+		return
+	}
+
+	pairs, err := h.photoSvc.Compare(c.Request.Context(), c.GetUint("user_id"), ownerID, c.Query("pose"), before, after)
+	if errors.Is(err, services.ErrUnknownPose) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare photos"})
+		return
+	}
+	c.JSON(http.StatusOK, pairs)
+}
+
+func (h *ProgressHandler) DeletePhoto(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	photoID, err := strconv.Atoi(c.Param("photo_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid photo_id"})
+		return
+	}
+	photo, err := h.repo.FindProgressPhoto(uint(photoID))
+	if err != nil || photo.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+
+	if err := h.photoSvc.Delete(c.Request.Context(), photo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete photo"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Photo deleted"})
+}
+
+// viewableOwner reads the user in the URL and checks that the requesting user may see their progress
+func (h *ProgressHandler) viewableOwner(c *gin.Context) (uint, bool) {
+	ownerID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		return 0, false
+	}
+	canView, err := h.photoSvc.CanView(c.GetUint("user_id"), uint(ownerID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check access"})
+		return 0, false
+	}
+	if !canView {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the user and their coaches can see this progress"})
+		return 0, false
+	}
+	return uint(ownerID), true
+}
+
+// ServePrivateFile serves files of the local private storage to requests with a valid signed URL
+func ServePrivateFile(store *storage.LocalPrivateStorage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimPrefix(c.Param("key"), "/")
+		if !store.Verify(key, c.Query("expires"), c.Query("signature")) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired link"})
+			return
+		}
+		file, err := store.Get(c.Request.Context(), key)
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+			return
+		}
+		defer file.Close()
+
+		c.Header("Cache-Control", "private, max-age=300")
+		c.DataFromReader(http.StatusOK, -1, mime.TypeByExtension(path.Ext(key)), file, nil)
+	}
+}
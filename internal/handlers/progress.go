@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"diplomIshi/internal/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type ProgressHandler struct {
+	repo           *repository.UserRepository
+	progressSvc    *services.ProgressService
+	photoSvc       *services.PhotoService
+	maxUploadBytes int64
+}
+
+func NewProgressHandler(repo *repository.UserRepository, progressSvc *services.ProgressService, photoSvc *services.PhotoService, maxUploadBytes int64) *ProgressHandler {
+	return &ProgressHandler{repo: repo, progressSvc: progressSvc, photoSvc: photoSvc, maxUploadBytes: maxUploadBytes}
+}
+
+// GetProgress lists the progress entries of the user in the URL, for the user and their coaches only
+func (h *ProgressHandler) GetProgress(c *gin.Context) {
+	userID, ok := h.viewableOwner(c)
+	if !ok {
+		return
+	}
+	var progressRecords []models.Progress
+	if err := h.repo.Db.Where("user_id = ?", userID).Find(&progressRecords).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, progressRecords)
+}
+
+// GetSeries returns a metric bucketed for charts
+// (?metric=weight&from=2006-01-02&to=2006-01-02&bucket=day|week|month&span=).
+// Metrics are weight, calories, steps and every body measurement, e.g. waist or body_fat.
+// The range defaults to the last 90 days and the trend line to a span of 4 buckets.
+// Like photos, the series is for the user and their coaches only.
+func (h *ProgressHandler) GetSeries(c *gin.Context) {
+	userID, ok := h.viewableOwner(c)
+	if !ok {
+		return
+	}
+
+	today, err := h.progressSvc.Today(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch progress series"})
+		return
+	}
+	to, err := parseDateQuery(c, "to", today)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, use YYYY-MM-DD"})
+		return
+	}
+	from, err := parseDateQuery(c, "from", to.AddDate(0, 0, -90))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, use YYYY-MM-DD"})
+		return
+	}
+	span, err := strconv.Atoi(c.DefaultQuery("span", "4"))
+	if err != nil || span < 1 || span > 52 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "span must be between 1 and 52"})
+		return
+	}
+
+	series, err := h.progressSvc.Series(userID, c.DefaultQuery("metric", "weight"), c.DefaultQuery("bucket", "week"), from, to, span)
+	if errors.Is(err, services.ErrUnknownMetric) || errors.Is(err, services.ErrUnknownBucket) || errors.Is(err, services.ErrInvalidRange) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch progress series"})
+		return
+	}
+	c.JSON(http.StatusOK, series)
+}
+
+// LogMeasurement records body measurements. Sending the same date again merges into that day's entry.
+// Fields listed in "clear", e.g. ["waist"], are removed from the entry.
+func (h *ProgressHandler) LogMeasurement(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var measurementData struct {
+		models.Measurement
+		Date  string   `json:"date"` // "2006-01-02", defaults to today; replaces the embedded time field
+		Clear []string `json:"clear"`
+	}
+	if err := c.ShouldBindJSON(&measurementData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	measurement := measurementData.Measurement
+	measurement.UserID = userID
+	measurement.Date = time.Time{}
+	if measurementData.Date != "" {
+		date, err := time.Parse("2006-01-02", measurementData.Date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, use YYYY-MM-DD"})
+			return
+		}
+		measurement.Date = date
+	}
+
+	err := h.progressSvc.LogMeasurement(&measurement, measurementData.Clear)
+	if errors.Is(err, services.ErrInvalidMeasurement) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save measurement"})
+		return
+	}
+	c.JSON(http.StatusOK, measurement)
+}
+
+// GetMeasurements lists the user's measurements, newest first (?from=&to=, the last year by default)
+func (h *ProgressHandler) GetMeasurements(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	today, err := h.progressSvc.Today(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch measurements"})
+		return
+	}
+	to, err := parseDateQuery(c, "to", today)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, use YYYY-MM-DD"})
+		return
+	}
+	from, err := parseDateQuery(c, "from", to.AddDate(-1, 0, 0))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, use YYYY-MM-DD"})
+		return
+	}
+
+	measurements, err := h.repo.GetMeasurements(userID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch measurements"})
+		return
+	}
+	c.JSON(http.StatusOK, measurements)
+}
+
+func (h *ProgressHandler) DeleteMeasurement(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	measurementID, err := strconv.Atoi(c.Param("measurement_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid measurement_id"})
+		return
+	}
+	measurement, err := h.repo.FindMeasurement(uint(measurementID))
+	if err != nil || measurement.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Measurement not found"})
+		return
+	}
+
+	if err := h.repo.DeleteMeasurement(measurement); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete measurement"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Measurement deleted"})
+}
+
+// parseDateQuery reads a YYYY-MM-DD query parameter, or returns fallback when it is missing
+func parseDateQuery(c *gin.Context, name string, fallback time.Time) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
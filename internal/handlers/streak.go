@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"diplomIshi/internal/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+func (h *UserHandler) GetStreaks(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	streaks, err := h.streakSvc.Streaks(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch streaks"})
+		return
+	}
+	c.JSON(http.StatusOK, streaks)
+}
+
+// GetStreakCalendar returns the active days of a streak (?from=&to=, default last 30 days)
+func (h *UserHandler) GetStreakCalendar(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+	var err error
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+	}
+
+	days, err := h.streakSvc.Calendar(userID, c.Param("type"), from, to)
+	if errors.Is(err, services.ErrUnknownStreakType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch streak calendar"})
+		return
+	}
+	c.JSON(http.StatusOK, days)
+}
+
+// BuyStreakFreeze spends points on a token that covers one missed day
+func (h *UserHandler) BuyStreakFreeze(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	streak, err := h.streakSvc.BuyFreeze(userID, c.Param("type"))
+	if errors.Is(err, services.ErrUnknownStreakType) || errors.Is(err, services.ErrNotEnoughPoints) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to buy streak freeze"})
+		return
+	}
+	c.JSON(http.StatusOK, streak)
+}
+
+// LogWater marks today's water goal as reached
+func (h *UserHandler) LogWater(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	streak, err := h.streakSvc.RecordActivity(userID, "water", time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update water streak"})
+		return
+	}
+	c.JSON(http.StatusOK, streak)
+}
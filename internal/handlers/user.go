@@ -4,21 +4,34 @@ import (
 	"diplomIshi/internal/models"
 	"diplomIshi/internal/repository"
 	"diplomIshi/internal/services"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// How many days back an item of the plan can still be completed
+const completionBackfillDays = 7
+
 type UserHandler struct {
-	repo        *repository.UserRepository
-	calcSvc     *services.CalculatorService
-	reminderSvc *services.ReminderService
+	repo           *repository.UserRepository
+	calcSvc        *services.CalculatorService
+	reminderSvc    *services.ReminderService
+	achievementSvc *services.AchievementService
+	streakSvc      *services.StreakService
+	levelSvc       *services.LevelService
+	leaderboardSvc *services.LeaderboardService
+	feedSvc        *services.FeedService
+	events         services.EventBus
 }
 
-func NewUserHandler(repo *repository.UserRepository, calcSvc *services.CalculatorService, reminderSvc *services.ReminderService) *UserHandler {
-	return &UserHandler{repo: repo, calcSvc: calcSvc, reminderSvc: reminderSvc}
+func NewUserHandler(repo *repository.UserRepository, calcSvc *services.CalculatorService, reminderSvc *services.ReminderService, achievementSvc *services.AchievementService, streakSvc *services.StreakService, levelSvc *services.LevelService, leaderboardSvc *services.LeaderboardService, feedSvc *services.FeedService, events services.EventBus) *UserHandler {
+	return &UserHandler{repo: repo, calcSvc: calcSvc, reminderSvc: reminderSvc, achievementSvc: achievementSvc, streakSvc: streakSvc, levelSvc: levelSvc, leaderboardSvc: leaderboardSvc, feedSvc: feedSvc, events: events}
 }
 
 func (h *UserHandler) Register(c *gin.Context) {
@@ -27,8 +40,30 @@ func (h *UserHandler) Register(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	user.Role = "user" // Roles can't be chosen at registration
+	user.WarningCount = 0
+	user.SuspendedUntil = time.Time{}
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(user.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timezone"})
+		return
+	}
+	if user.Username != nil {
+		username := strings.ToLower(*user.Username)
+		if !services.UsernamePattern.MatchString(username) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Username must be 3-30 letters, digits or underscores"})
+			return
+		}
+		user.Username = &username
+	}
 
 	if err := h.repo.Create(&user); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Username is taken"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
 		return
 	}
@@ -53,8 +88,9 @@ func (h *UserHandler) AddProgress(c *gin.Context) {
 		return
 	}
 
-	// Ensure the user exists
-	_, err := h.repo.FindByID(progress.UserID)
+	// Progress is always logged for the authenticated user, whatever the body says
+	progress.UserID = c.GetUint("user_id")
+	user, err := h.repo.FindByID(progress.UserID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
@@ -65,18 +101,22 @@ func (h *UserHandler) AddProgress(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, progress)
-}
-
-func (h *UserHandler) GetProgress(c *gin.Context) {
-	userID := c.Param("user_id")
-	var progressRecords []models.Progress
-	if err := h.repo.Db.Where("user_id = ?", userID).Find(&progressRecords).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch progress"})
+	// Logged calories count towards the food log and calorie target streaks
+	loggedAt := progress.Date
+	if loggedAt.IsZero() {
+		loggedAt = time.Now()
+	}
+	if err := h.streakSvc.RecordCalories(user, progress.Calories, loggedAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update streaks"})
+		return
+	}
+	if err := h.feedSvc.PublishMilestones(user, &progress); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check milestones"})
 		return
 	}
+	h.achievementSvc.EvaluateAsync(progress.UserID)
 
-	c.JSON(http.StatusOK, progressRecords)
+	c.JSON(http.StatusCreated, progress)
 }
 
 func (h *UserHandler) GetPlan(c *gin.Context) {
@@ -148,34 +188,173 @@ func (h *UserHandler) Login(c *gin.Context) {
 func (h *UserHandler) CompleteAction(c *gin.Context) {
 	userID := c.GetUint("user_id") // From AuthMiddleware
 	var actionData struct {
-		Action string `json:"action"` // "meal" or "exercise"
+		Action     string `json:"action" binding:"required"`  // "meal" or "exercise"
+		ItemID     uint   `json:"item_id" binding:"required"` // Planned Meal.ID or Exercise.ID
+		Date       string `json:"date,omitempty"`             // "2006-01-02", defaults to today
+		Difficulty string `json:"difficulty,omitempty"`       // e.g., "easy", "hard"
 	}
 	if err := c.ShouldBindJSON(&actionData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// A retried request with the same key returns the original result
+	key := c.GetHeader("Idempotency-Key")
+	if key != "" && h.replayCompletion(c, userID, key) {
+		return
+	}
+
+	user, err := h.repo.FindByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	today, err := h.streakSvc.UserDay(userID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	date := today
+	if actionData.Date != "" {
+		parsed, err := time.Parse("2006-01-02", actionData.Date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+			return
+		}
+		date = parsed
+	}
+	if date.After(today) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot complete an item in the future"})
+		return
+	}
+	if date.Before(today.AddDate(0, 0, -completionBackfillDays)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot complete an item more than " + strconv.Itoa(completionBackfillDays) + " days ago"})
+		return
+	}
+
+	if !h.calcSvc.InPlan(user, actionData.Action, actionData.ItemID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Planned item not found"})
+		return
+	}
+
+	if _, err := h.repo.FindCompletion(userID, actionData.Action, actionData.ItemID, date); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Item already completed"})
+		return
+	}
+
 	// Award points
-	point := h.calcSvc.AwardPoints(userID, actionData.Action)
-	if err := h.repo.AddPoint(&point); err != nil {
+	point, pointCap, err := h.calcSvc.AwardPoints(userID, actionData.Action, actionData.Difficulty)
+	if errors.Is(err, services.ErrUnknownAction) || errors.Is(err, services.ErrUnknownDifficulty) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to award points"})
 		return
 	}
 
-	// Check achievements (only for exercise)
-	if actionData.Action == "exercise" {
-		recentDays, err := h.repo.GetRecentExerciseDays(userID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check achievements"})
+	completion := models.Completion{
+		UserID:   userID,
+		ItemType: actionData.Action,
+		ItemID:   actionData.ItemID,
+		Date:     date,
+	}
+	if key != "" {
+		completion.IdempotencyKey = &key
+	}
+
+	// Nothing to store for an action worth no points
+	var awarded *models.Point
+	if point.Points > 0 {
+		awarded = &point
+	}
+	xpBefore, xpAfter, err := h.repo.CreateCompletion(&completion, awarded, pointCap)
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			// A concurrent request with the same key got there first
+			if key != "" && h.replayCompletion(c, userID, key) {
+				return
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "Item already completed"})
 			return
 		}
-		achievements := h.calcSvc.CheckAchievements(userID, recentDays)
-		for _, ach := range achievements {
-			h.repo.AddAchievement(&ach)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save completion"})
+		return
+	}
+
+	streakType := "food_log"
+	if actionData.Action == "exercise" {
+		streakType = "workout"
+	}
+	if _, err := h.streakSvc.RecordDay(userID, streakType, date); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update streaks"})
+		return
+	}
+
+	achievements, err := h.achievementSvc.Evaluate(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check achievements"})
+		return
+	}
+
+	response := gin.H{"points": point, "completion": completion, "achievements": achievements}
+	event := gin.H{"points": point, "total_xp": xpAfter}
+	if level, ok := h.levelSvc.LeveledUp(xpBefore, xpAfter); ok {
+		if err := h.levelSvc.NotifyLevelUp(userID, level); err != nil {
+			log.Printf("Failed to notify user %d of level %d: %v", userID, level, err)
+		}
+		response["level_up"] = level
+		event["level_up"] = level
+	}
+	if completion.PointID != nil {
+		h.events.Publish(userID, services.Event{Type: "points", Data: event})
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// replayCompletion answers with the completion stored under the idempotency key and its points.
+// It returns false, without responding, when there is no such completion.
+func (h *UserHandler) replayCompletion(c *gin.Context, userID uint, key string) bool {
+	existing, err := h.repo.FindCompletionByKey(userID, key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check completion"})
+		return true
+	}
+	response := gin.H{"completion": existing}
+	if existing.PointID != nil {
+		if point, err := h.repo.FindPoint(*existing.PointID); err == nil {
+			response["points"] = point
 		}
 	}
+	c.JSON(http.StatusOK, response)
+	return true
+}
+
+// UndoCompletion reverses a completion and the points it awarded
+func (h *UserHandler) UndoCompletion(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	completionID, err := strconv.Atoi(c.Param("completion_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid completion_id"})
+		return
+	}
+
+	completion, err := h.repo.FindCompletionByID(uint(completionID), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Completion not found"})
+		return
+	}
+
+	if err := h.streakSvc.UndoCompletion(completion); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reverse completion"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"points": point})
+	c.JSON(http.StatusOK, gin.H{"message": "Completion reversed"})
 }
 
 func (h *UserHandler) GetGamificationData(c *gin.Context) {
@@ -198,18 +377,36 @@ func (h *UserHandler) GetGamificationData(c *gin.Context) {
 		return
 	}
 
-	totalPoints := 0
-	for _, p := range points {
-		totalPoints += p.Points
+	level, err := h.levelSvc.UserLevel(uint(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch level"})
+		return
+	}
+	spent, err := h.repo.GetSpentPoints(uint(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch points"})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"total_points": totalPoints,
-		"points":       points,
-		"achievements": achievements,
+		"total_points":     level.TotalXP,
+		"spendable_points": level.TotalXP - spent, // Balance for streak freezes
+		"level":            level,
+		"points":           points,
+		"achievements":     achievements,
 	})
 }
 
+func (h *UserHandler) GetAchievements(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	progress, err := h.achievementSvc.Progress(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
+		return
+	}
+	c.JSON(http.StatusOK, progress)
+}
+
 func (h *UserHandler) CreateReminder(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	var reminder models.Reminder
@@ -220,6 +417,12 @@ func (h *UserHandler) CreateReminder(c *gin.Context) {
 	reminder.UserID = userID
 	reminder.Active = true
 
+	// Validate the schedule before anything is saved
+	if err := h.reminderSvc.PrepareReminder(&reminder); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := h.repo.CreateReminder(&reminder); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reminder"})
 		return
@@ -230,9 +433,35 @@ func (h *UserHandler) CreateReminder(c *gin.Context) {
 		return
 	}
 
+	reminder.NextRuns, _ = h.reminderSvc.Preview(&reminder, previewCount(c))
 	c.JSON(http.StatusCreated, reminder)
 }
 
+func (h *UserHandler) PreviewReminder(c *gin.Context) {
+	var reminder models.Reminder
+	if err := c.ShouldBindJSON(&reminder); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reminder.UserID = c.GetUint("user_id")
+
+	if err := h.reminderSvc.PrepareReminder(&reminder); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	nextRuns, err := h.reminderSvc.Preview(&reminder, previewCount(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"time":      reminder.Time,
+		"next_runs": nextRuns,
+	})
+}
+
 func (h *UserHandler) GetReminders(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	reminders, err := h.repo.GetReminders(userID)
@@ -240,9 +469,29 @@ func (h *UserHandler) GetReminders(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reminders"})
 		return
 	}
+	count := previewCount(c)
+	for i := range reminders {
+		reminders[i].NextRuns, _ = h.reminderSvc.Preview(&reminders[i], count)
+	}
 	c.JSON(http.StatusOK, reminders)
 }
 
+func (h *UserHandler) GetReminderLogs(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	reminderID, err := strconv.Atoi(c.Param("reminder_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reminder_id"})
+		return
+	}
+
+	logs, err := h.repo.GetReminderLogs(uint(reminderID), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reminder history"})
+		return
+	}
+	c.JSON(http.StatusOK, logs)
+}
+
 func (h *UserHandler) DisableReminder(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	reminderID := c.Param("reminder_id")
@@ -262,6 +511,33 @@ func (h *UserHandler) DisableReminder(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Reminder disabled"})
 }
 
+// SetUsername sets the handle others use to @mention the user
+func (h *UserHandler) SetUsername(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var usernameData struct {
+		Username string `json:"username" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&usernameData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	username := strings.ToLower(usernameData.Username)
+	if !services.UsernamePattern.MatchString(username) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username must be 3-30 letters, digits or underscores"})
+		return
+	}
+
+	if err := h.repo.Db.Model(&models.User{}).Where("id = ?", userID).Update("username", username).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Username is taken"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update username"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"username": username})
+}
+
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	var updateData struct {
@@ -271,6 +547,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		ActivityLevel      string  `json:"activity_level,omitempty"`
 		EatingHabits       string  `json:"eating_habits,omitempty"`
 		Goal               string  `json:"goal,omitempty"`
+		TargetWeight       float64 `json:"target_weight,omitempty"`
 	}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -302,6 +579,9 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	if updateData.Goal != "" {
 		user.Goal = updateData.Goal
 	}
+	if updateData.TargetWeight > 0 {
+		user.TargetWeight = updateData.TargetWeight
+	}
 	user.LastUpdated = time.Now()
 
 	if err := h.repo.Update(user); err != nil {
@@ -345,3 +625,12 @@ func (h *UserHandler) GetAllUsers(c *gin.Context) {
 		"users": users,
 	})
 }
+
+// previewCount reads the number of upcoming fire times to return (?count=, default 5)
+func previewCount(c *gin.Context) int {
+	count, err := strconv.Atoi(c.DefaultQuery("count", "5"))
+	if err != nil || count <= 0 {
+		return 5
+	}
+	return count
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Activity is something a user did that shows up in their followers' home feeds
+type Activity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Type      string    `gorm:"index:idx_activity_ref" json:"type"`             // "post", "achievement" or "milestone"
+	RefID     uint      `gorm:"index:idx_activity_ref" json:"ref_id,omitempty"` // Post or achievement ID
+	Kind      string    `json:"kind,omitempty"`                                 // Milestone kind: "step_record" or "weight_goal"
+	Title     string    `json:"title"`
+	Value     float64   `json:"value,omitempty"` // Record steps or reached weight
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FeedEntry puts an activity into one user's home feed when feeds are fanned out on write
+type FeedEntry struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"uniqueIndex:idx_feed_entry,priority:1" json:"user_id"`
+	ActivityID uint      `gorm:"uniqueIndex:idx_feed_entry,priority:2;index" json:"activity_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// FeedItem is one home feed entry with its author and, for posts, the post itself
+type FeedItem struct {
+	Activity
+	AuthorName   string    `json:"author_name"`
+	AuthorAvatar string    `json:"author_avatar"`
+	Post         *FeedPost `gorm:"-" json:"post,omitempty"`
+}
+
+// UserSummary is the public face of a user in lists such as followers
+type UserSummary struct {
+	ID        uint   `json:"id"`
+	FullName  string `json:"full_name"`
+	AvatarURL string `json:"avatar_url"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+type Challenge struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CreatorID   uint      `json:"creator_id"`
+	Title       string    `json:"title" binding:"required"`
+	Description string    `json:"description"`
+	Metric      string    `json:"metric" binding:"required"` // "workouts", "points", "steps" or "weight_loss_percent"
+	Visibility  string    `json:"visibility"`                // "open" (anyone can join) or "invite"
+	StartsAt    time.Time `json:"starts_at" binding:"required"`
+	EndsAt      time.Time `gorm:"index" json:"ends_at" binding:"required"`
+	Status      string    `gorm:"index" json:"status"` // "active" or "closed"
+	WinnerID    *uint     `json:"winner_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ChallengeParticipant struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	ChallengeID uint       `gorm:"uniqueIndex:idx_challenge_participant" json:"challenge_id"`
+	UserID      uint       `gorm:"uniqueIndex:idx_challenge_participant" json:"user_id"`
+	Status      string     `json:"status"` // "invited" or "joined"
+	JoinedAt    *time.Time `json:"joined_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ChallengeStanding is a participant's computed score in a challenge
+type ChallengeStanding struct {
+	Rank     int     `json:"rank"`
+	UserID   uint    `json:"user_id"`
+	FullName string  `json:"full_name"`
+	Score    float64 `json:"score"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Completion records that a user finished a planned meal or exercise on a given day.
+// The unique index makes completing the same item twice on one day impossible.
+type Completion struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	UserID         uint      `gorm:"uniqueIndex:idx_completion_item;uniqueIndex:idx_completion_key" json:"user_id"`
+	ItemType       string    `gorm:"uniqueIndex:idx_completion_item" json:"item_type"` // "meal" or "exercise"
+	ItemID         uint      `gorm:"uniqueIndex:idx_completion_item" json:"item_id"`   // Meal.ID or Exercise.ID
+	Date           time.Time `gorm:"type:date;uniqueIndex:idx_completion_item" json:"date"`
+	PointID        *uint     `json:"point_id,omitempty"`                                              // Awarded point, nil if the daily cap was reached
+	IdempotencyKey *string   `gorm:"uniqueIndex:idx_completion_key" json:"idempotency_key,omitempty"` // From the Idempotency-Key header
+	CreatedAt      time.Time `json:"created_at"`
+}
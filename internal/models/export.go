@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// ExportJob builds a ZIP of everything stored about a user in the background.
+// The archive is private, downloaded through a signed link and deleted once the job expires.
+type ExportJob struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"index" json:"user_id"`
+	RequestedBy uint       `json:"requested_by"`        // An admin handling a subject access request, otherwise the user
+	Status      string     `gorm:"index" json:"status"` // "pending", "running", "ready", "failed" or "expired"
+	Key         string     `json:"-"`                   // Private storage key of the archive
+	Size        int        `json:"size,omitempty"`      // Bytes of the archive
+	Error       string     `json:"error,omitempty"`     // Why the job failed
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`            // The archive is deleted after this time
+	DownloadURL string     `gorm:"-" json:"download_url,omitempty"` // Signed, valid until ExpiresAt
+}
+
+// LoggedItem is a completed meal or exercise with the plan item it refers to
+type LoggedItem struct {
+	ID             uint      `json:"id"`
+	Date           time.Time `json:"date"`
+	ItemType       string    `json:"item_type"` // "meal" or "exercise"
+	ItemID         uint      `json:"item_id"`
+	Name           string    `json:"name"`
+	MealType       string    `json:"meal_type,omitempty"`
+	Calories       int       `json:"calories,omitempty"` // Eaten for meals
+	Protein        int       `json:"protein,omitempty"`
+	Carbs          int       `json:"carbs,omitempty"`
+	Fat            int       `json:"fat,omitempty"`
+	Duration       int       `json:"duration,omitempty"` // Minutes, for exercises
+	CaloriesBurned int       `json:"calories_burned,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// GroupMembership is a user's place in a group together with the group's name
+type GroupMembership struct {
+	GroupID   uint       `json:"group_id"`
+	GroupName string     `json:"group_name"`
+	Role      string     `json:"role"`
+	Status    string     `json:"status"` // "member", "requested" or "invited"
+	CreatedAt time.Time  `json:"created_at"`
+	JoinedAt  *time.Time `json:"joined_at,omitempty"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// FeedPost is a post as shown in the community feed
+type FeedPost struct {
+	Post
+	AuthorName   string           `json:"author_name"`
+	AuthorAvatar string           `json:"author_avatar"`
+	Score        float64          `json:"-"` // Sort key of "top" and "hot"
+	CommentCount int              `gorm:"-" json:"comment_count"`
+	Reactions    *ReactionSummary `gorm:"-" json:"reactions"`
+}
+
+// PostCursor marks the last post of a feed page. The next page starts right after it.
+type PostCursor struct {
+	Sort      string    `json:"sort"`
+	Score     float64   `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+	ID        uint      `json:"id"`
+	AsOf      time.Time `json:"as_of"` // "hot" scores are computed at this time, so they don't shift between pages
+}
+
+// SearchResult is a post or comment matching a community search
+type SearchResult struct {
+	Type         string    `json:"type"` // "post" or "comment"
+	ID           uint      `json:"id"`
+	PostID       uint      `json:"post_id"`
+	UserID       uint      `json:"user_id"`
+	AuthorName   string    `json:"author_name"`
+	AuthorAvatar string    `json:"author_avatar"`
+	Title        string    `json:"title,omitempty"` // Post title with matches highlighted
+	Snippet      string    `json:"snippet"`         // Matching fragments with <mark> around the matches
+	Rank         float64   `json:"rank"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Group is a club inside the community, e.g. "Morning runners Tashkent"
+type Group struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `json:"name" binding:"required"`
+	Description string    `json:"description"`
+	Membership  string    `json:"membership"` // "open", "request" or "invite"
+	CreatorID   uint      `json:"creator_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// GroupMember is a user's place in a group. Only "member" status grants access to the group feed.
+type GroupMember struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	GroupID   uint       `gorm:"uniqueIndex:idx_group_member" json:"group_id"`
+	UserID    uint       `gorm:"uniqueIndex:idx_group_member;index" json:"user_id"`
+	Role      string     `gorm:"default:member" json:"role"` // "admin" or "member"
+	Status    string     `json:"status"`                     // "member", "requested" or "invited"
+	CreatedAt time.Time  `json:"created_at"`
+	JoinedAt  *time.Time `json:"joined_at,omitempty"`
+	FullName  string     `gorm:"-:migration;->" json:"full_name,omitempty"` // Filled in member lists
+}
+
+// GroupRanking is one row of the leaderboard between groups
+type GroupRanking struct {
+	Rank    int    `json:"rank"`
+	GroupID uint   `json:"group_id"`
+	Name    string `json:"name"`
+	Members int    `json:"members"`
+	Points  int    `json:"points"` // Sum of the members' points in the period
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// PointAggregate is the materialized sum of a user's points for one leaderboard window.
+// Rows are updated together with the points, so rankings never sum the points table.
+type PointAggregate struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"uniqueIndex:idx_point_aggregate" json:"user_id"`
+	Period      string    `gorm:"uniqueIndex:idx_point_aggregate;index:idx_point_ranking,priority:1" json:"period"`                 // "week", "month" or "all"
+	PeriodStart time.Time `gorm:"type:date;uniqueIndex:idx_point_aggregate;index:idx_point_ranking,priority:2" json:"period_start"` // Monday of the week, 1st of the month, 1970-01-01 for "all"
+	Points      int       `gorm:"index:idx_point_ranking,priority:3,sort:desc" json:"points"`
+}
+
+// Follow is a one-way connection between users. Two users following each other are friends.
+type Follow struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	FollowerID  uint      `gorm:"uniqueIndex:idx_follow" json:"follower_id"`
+	FollowingID uint      `gorm:"uniqueIndex:idx_follow;index" json:"following_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// LeaderboardEntry is one ranked row of a leaderboard
+type LeaderboardEntry struct {
+	Rank     int    `json:"rank"`
+	UserID   uint   `json:"user_id"`
+	FullName string `json:"full_name"`
+	Points   int    `json:"points"`
+}
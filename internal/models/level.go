@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// UserXP caches the running total of a user's points. It is updated in the same
+// transaction that creates or deletes a Point.
+type UserXP struct {
+	UserID      uint      `gorm:"primaryKey" json:"user_id"`
+	TotalXP     int       `json:"total_xp"`
+	SpentPoints int       `json:"spent_points"` // Spent on e.g. streak freezes; lowers the balance, never the XP
+	UpdatedAt   time.Time `json:"updated_at"`
+}
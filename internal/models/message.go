@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Conversation is a 1:1 chat. The two users are stored in ascending order, so each pair has a single row.
+type Conversation struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UserLowID     uint      `gorm:"uniqueIndex:idx_conversation" json:"user_low_id"`
+	UserHighID    uint      `gorm:"uniqueIndex:idx_conversation;index" json:"user_high_id"`
+	LastMessageAt time.Time `json:"last_message_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type Message struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	ConversationID uint       `gorm:"index" json:"conversation_id"`
+	SenderID       uint       `json:"sender_id"`
+	RecipientID    uint       `gorm:"index" json:"recipient_id"`
+	Content        string     `json:"content"`
+	ReadAt         *time.Time `json:"read_at,omitempty"` // Read receipt, set when the recipient opens the conversation
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// ConversationSummary is a conversation as seen by one of its users, for the conversation list
+type ConversationSummary struct {
+	ID            uint      `json:"id"`
+	PeerID        uint      `json:"peer_id"`
+	PeerName      string    `json:"peer_name"`
+	PeerAvatar    string    `json:"peer_avatar"`
+	PeerRole      string    `json:"peer_role"` // Lets the app mark chats with coaches
+	LastMessage   string    `json:"last_message"`
+	LastSenderID  uint      `json:"last_sender_id"`
+	LastMessageAt time.Time `json:"last_message_at"`
+	UnreadCount   int       `json:"unread_count"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Report is a user's complaint about a post or comment. Reports without a reporter come from the content filter.
+type Report struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	ReporterID *uint      `gorm:"uniqueIndex:idx_report" json:"reporter_id,omitempty"`
+	TargetType string     `gorm:"uniqueIndex:idx_report;index:idx_report_target" json:"target_type"` // "post" or "comment"
+	TargetID   uint       `gorm:"uniqueIndex:idx_report;index:idx_report_target" json:"target_id"`
+	Reason     string     `json:"reason"`
+	Status     string     `gorm:"index;default:open" json:"status"` // "open", "resolved" or "dismissed"
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// ModerationAction is the audit log entry of every moderation decision, manual or automatic
+type ModerationAction struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	ModeratorID *uint      `gorm:"index" json:"moderator_id,omitempty"`            // Nil for the automatic filter
+	UserID      uint       `gorm:"index" json:"user_id"`                           // Author the action concerns
+	Action      string     `json:"action"`                                         // "hide", "unhide", "delete", "warn", "suspend", "dismiss", "set_role", "filter_reject" or "filter_flag"
+	TargetType  string     `gorm:"index:idx_moderation_target" json:"target_type"` // "post", "comment" or "user"
+	TargetID    uint       `gorm:"index:idx_moderation_target" json:"target_id"`
+	Reason      string     `json:"reason"`
+	Until       *time.Time `json:"until,omitempty"` // End of a suspension
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ReportQueueItem groups the open reports about one post or comment for review
+type ReportQueueItem struct {
+	TargetType      string    `json:"target_type"`
+	TargetID        uint      `json:"target_id"`
+	ReportCount     int       `json:"report_count"`
+	FirstReportedAt time.Time `json:"first_reported_at"`
+	Reports         []Report  `gorm:"-" json:"reports"`
+	Post            *Post     `gorm:"-" json:"post,omitempty"`
+	Comment         *Comment  `gorm:"-" json:"comment,omitempty"`
+}
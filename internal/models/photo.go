@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// ProgressPhoto is a private photo of the user's body. The original upload is stored apart from
+// its resized variants, and none of them are public: the URLs are signed and expire.
+type ProgressPhoto struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"index" json:"user_id"`
+	Date         time.Time `gorm:"type:date" json:"date"`
+	Pose         string    `json:"pose"` // "front", "side" or "back"; comparisons pair photos of the same pose
+	Note         string    `json:"note,omitempty"`
+	OriginalKey  string    `json:"-"`
+	DisplayKey   string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	ContentType  string    `json:"content_type"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Size         int       `json:"size"` // Bytes of the original
+	CreatedAt    time.Time `json:"created_at"`
+	OriginalURL  string    `gorm:"-" json:"original_url,omitempty"` // Only for the owner
+	URL          string    `gorm:"-" json:"url"`                    // Display-sized variant
+	ThumbnailURL string    `gorm:"-" json:"thumbnail_url"`
+}
+
+// PhotoPair is a before/after comparison of one pose
+type PhotoPair struct {
+	Pose   string        `json:"pose"`
+	Before ProgressPhoto `json:"before"`
+	After  ProgressPhoto `json:"after"`
+	Days   int           `json:"days"` // Days between the two photos
+}
+
+// CoachClient lets a coach see the client's private progress, e.g. their photos.
+// The client grants and revokes the access.
+type CoachClient struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CoachID   uint      `gorm:"uniqueIndex:idx_coach_client;index" json:"coach_id"`
+	ClientID  uint      `gorm:"uniqueIndex:idx_coach_client" json:"client_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
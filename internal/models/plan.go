@@ -23,41 +23,125 @@ type Exercise struct {
 type Point struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	UserID    uint      `json:"user_id"`
+	Action    string    `gorm:"index" json:"action"` // e.g., "meal", "exercise"
 	Points    int       `json:"points"`
 	Reason    string    `json:"reason"` // e.g., "Completed meal", "Completed exercise"
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// PointRule describes how many points an action is worth. Rules are read on every award,
+// so admins can change them without a redeploy.
+type PointRule struct {
+	ID                    uint               `gorm:"primaryKey" json:"id"`
+	Action                string             `gorm:"uniqueIndex" json:"action"` // e.g., "meal", "exercise"
+	Reason                string             `json:"reason"`                    // Stored on awarded points
+	BasePoints            int                `json:"base_points"`
+	StreakBonus           float64            `json:"streak_bonus"`                                            // Extra share of base points per consecutive day, e.g., 0.1
+	MaxStreakBonus        float64            `json:"max_streak_bonus"`                                        // Upper limit for the streak bonus, e.g., 1.0 = double points
+	DifficultyMultipliers map[string]float64 `gorm:"serializer:json" json:"difficulty_multipliers,omitempty"` // e.g., {"easy": 1, "hard": 1.5}
+	DailyCap              int                `json:"daily_cap"`                                               // Max points per day for this action, 0 = unlimited
+	Active                bool               `json:"active"`
+	UpdatedAt             time.Time          `json:"updated_at"`
+}
+
+// PointCap is a rule's daily cap: the action earns at most Limit points from Since on.
+// It is applied while the point is stored, so concurrent awards can't exceed it.
+type PointCap struct {
+	Limit int
+	Since time.Time
+}
+
 type Achievement struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `json:"user_id"`
-	Name      string    `json:"name"` // e.g., "5-Day Streak"
+	UserID    uint      `gorm:"uniqueIndex:idx_user_achievement" json:"user_id"`
+	Key       string    `gorm:"uniqueIndex:idx_user_achievement" json:"key"` // AchievementDefinition.Key, unlocked once per user
+	Name      string    `json:"name"`                                        // e.g., "5-Day Streak"
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// AchievementDefinition is a catalog entry unlocked when the user's metric reaches Threshold
+type AchievementDefinition struct {
+	ID          uint    `gorm:"primaryKey" json:"id"`
+	Key         string  `gorm:"uniqueIndex" json:"key"` // e.g., "workout_streak_5"
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	Icon        string  `json:"icon"`
+	Criteria    string  `json:"criteria"`  // "workout_streak", "total_workouts", "weight_lost", "posts_made", "total_points" or "level"
+	Threshold   float64 `json:"threshold"` // e.g., 5 days, 10 workouts, 5 kg
+}
+
 type Reminder struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `json:"user_id"`
-	Type      string    `json:"type"`    // "meal" or "exercise"
-	Time      string    `json:"time"`    // Cron format, e.g., "0 8 * * *" (8:00 AM daily)
-	Message   string    `json:"message"` // e.g., "Time for breakfast!"
-	Active    bool      `json:"active"`  // Enable/disable reminder
-	CreatedAt time.Time `json:"created_at"`
+	ID        uint               `gorm:"primaryKey" json:"id"`
+	UserID    uint               `json:"user_id"`
+	Type      string             `json:"type"`                                       // "meal" or "exercise"
+	Time      string             `json:"time"`                                       // Cron format, e.g., "0 8 * * *" (8:00 AM daily)
+	Schedule  *ReminderSchedule  `gorm:"serializer:json" json:"schedule,omitempty"`  // Human-friendly schedule, compiled into Time
+	Condition *ReminderCondition `gorm:"serializer:json" json:"condition,omitempty"` // Only fire when the condition holds
+	Message   string             `json:"message"`                                    // e.g., "Time for breakfast!"
+	Active    bool               `json:"active"`                                     // Enable/disable reminder
+	CreatedAt time.Time          `json:"created_at"`
+	NextRuns  []time.Time        `gorm:"-" json:"next_runs,omitempty"` // Upcoming fire times, not stored
+	Timezone  string             `gorm:"-:migration;->" json:"-"`      // The user's, the schedule runs on their clock
+}
+
+type ReminderSchedule struct {
+	TimeOfDay     string     `json:"time_of_day"`              // "HH:MM", e.g., "08:00"
+	DaysOfWeek    []int      `json:"days_of_week,omitempty"`   // 0 = Sunday ... 6 = Saturday, empty = every day
+	IntervalHours int        `json:"interval_hours,omitempty"` // Repeat every N hours after TimeOfDay until midnight
+	EndDate       *time.Time `json:"end_date,omitempty"`       // No reminders after this moment
+}
+
+type ReminderCondition struct {
+	Type   string `json:"type"`             // "no_exercise_today", "no_meal_today", "no_diary_today", "no_progress_days" or "points_below"
+	Days   int    `json:"days,omitempty"`   // For "no_progress_days": fire if nothing was logged for this many days
+	Points int    `json:"points,omitempty"` // For "points_below": fire while today's points are under this
+}
+
+type ReminderLog struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ReminderID  uint      `gorm:"uniqueIndex:idx_reminder_occurrence" json:"reminder_id"`
+	ScheduledAt time.Time `gorm:"uniqueIndex:idx_reminder_occurrence" json:"scheduled_at"` // One row per occurrence, claimed by a single instance
+	UserID      uint      `json:"user_id"`
+	Status      string    `json:"status"`           // "pending", "sent" or "skipped"
+	Reason      string    `json:"reason,omitempty"` // Why the reminder was skipped
+	FiredAt     time.Time `json:"fired_at"`
 }
 
 type Post struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `json:"user_id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint        `gorm:"primaryKey" json:"id"`
+	UserID    uint        `json:"user_id"`
+	GroupID   *uint       `gorm:"index" json:"group_id,omitempty"` // Only members see posts in a group
+	Title     string      `json:"title"`
+	Content   string      `json:"content"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	HiddenAt  *time.Time  `json:"hidden_at,omitempty"` // Hidden by a moderator
+	Images    []PostImage `gorm:"foreignKey:PostID" json:"images"`
+}
+
+type PostImage struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	PostID       uint      `gorm:"index" json:"post_id"`
+	Key          string    `json:"-"` // Storage key of the original
+	ThumbnailKey string    `json:"-"`
+	ContentType  string    `json:"content_type"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Size         int       `json:"size"` // Bytes of the stored original
+	CreatedAt    time.Time `json:"created_at"`
+	URL          string    `gorm:"-" json:"url"`
+	ThumbnailURL string    `gorm:"-" json:"thumbnail_url"`
 }
 
 type Comment struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `json:"user_id"`
-	PostID    uint      `json:"post_id"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `json:"user_id"`
+	PostID    uint       `gorm:"index" json:"post_id"`
+	ParentID  *uint      `gorm:"index" json:"parent_id,omitempty"` // Comment being replied to, nil for top-level comments
+	Depth     int        `json:"depth"`                            // 0 for top-level comments
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // Soft delete, the row stays so replies keep their parent
+	HiddenAt  *time.Time `json:"hidden_at,omitempty"`  // Hidden by a moderator
 }
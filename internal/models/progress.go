@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// SeriesBucket aggregates one metric's readings over a day, week or month
+type SeriesBucket struct {
+	Start time.Time `json:"start"`
+	Avg   float64   `json:"avg"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Count int       `json:"count"`
+	Trend float64   `json:"trend" gorm:"-"` // Exponential moving average of Avg up to this bucket
+}
+
+// SeriesReading is a single reading of a metric
+type SeriesReading struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+}
+
+// Measurement is the body measurements of a user on one day. Fields that weren't measured are nil,
+// and logging the same day again fills in or overwrites only the fields sent.
+type Measurement struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	UserID           uint      `gorm:"uniqueIndex:idx_measurement_day" json:"user_id"`
+	Date             time.Time `gorm:"type:date;uniqueIndex:idx_measurement_day" json:"date"`
+	Waist            *float64  `json:"waist,omitempty"` // Circumferences in cm
+	Hips             *float64  `json:"hips,omitempty"`
+	Chest            *float64  `json:"chest,omitempty"`
+	Neck             *float64  `json:"neck,omitempty"`
+	Arms             *float64  `json:"arms,omitempty"`
+	Thighs           *float64  `json:"thighs,omitempty"`
+	BodyFat          *float64  `json:"body_fat,omitempty"`           // Percent
+	RestingHeartRate *float64  `json:"resting_heart_rate,omitempty"` // Beats per minute
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
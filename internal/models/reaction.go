@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Reaction is a user's single reaction to a post or comment
+type Reaction struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"uniqueIndex:idx_user_reaction" json:"user_id"`
+	TargetType string    `gorm:"uniqueIndex:idx_user_reaction;index:idx_reaction_target" json:"target_type"` // "post" or "comment"
+	TargetID   uint      `gorm:"uniqueIndex:idx_user_reaction;index:idx_reaction_target" json:"target_id"`
+	Type       string    `json:"type"` // "like", "fire", "strong" or "clap"
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ReactionSummary is attached to posts and comments in listings
+type ReactionSummary struct {
+	Counts     map[string]int `json:"counts"`
+	Total      int            `json:"total"`
+	MyReaction string         `json:"my_reaction,omitempty"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Tag is a #hashtag, stored lowercase
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"uniqueIndex" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagUse links a tag to the post or comment that contains it
+type TagUse struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TagID      uint      `gorm:"uniqueIndex:idx_tag_use;index:idx_tag_trending,priority:1" json:"tag_id"`
+	TargetType string    `gorm:"uniqueIndex:idx_tag_use;index:idx_tag_use_target" json:"target_type"` // "post" or "comment"
+	TargetID   uint      `gorm:"uniqueIndex:idx_tag_use;index:idx_tag_use_target" json:"target_id"`
+	PostID     uint      `gorm:"index" json:"post_id"` // The post itself or the one the comment belongs to
+	UserID     uint      `json:"user_id"`
+	CreatedAt  time.Time `gorm:"index:idx_tag_trending,priority:2" json:"created_at"`
+}
+
+// TrendingTag is a tag with its recent usage
+type TrendingTag struct {
+	Name  string `json:"name"`
+	Uses  int    `json:"uses"`
+	Users int    `json:"users"` // Distinct authors, which ranks the tag
+}
+
+// Mention is an @username in a post or comment
+type Mention struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	TargetType      string    `gorm:"uniqueIndex:idx_mention" json:"target_type"` // "post" or "comment"
+	TargetID        uint      `gorm:"uniqueIndex:idx_mention" json:"target_id"`
+	MentionedUserID uint      `gorm:"uniqueIndex:idx_mention;index" json:"mentioned_user_id"`
+	PostID          uint      `gorm:"index" json:"post_id"`
+	AuthorID        uint      `json:"author_id"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// Notification is an entry in a user's in-app inbox
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index" json:"user_id"` // Recipient
+	ActorID   uint       `json:"actor_id"`
+	Type      string     `json:"type"` // "mention", "level_up" or "group_invite"
+	PostID    *uint      `gorm:"index" json:"post_id,omitempty"`
+	CommentID *uint      `json:"comment_id,omitempty"`
+	GroupID   *uint      `json:"group_id,omitempty"`
+	Text      string     `json:"text"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Block stops a user from mentioning or contacting the blocker
+type Block struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	BlockerID uint      `gorm:"uniqueIndex:idx_block" json:"blocker_id"`
+	BlockedID uint      `gorm:"uniqueIndex:idx_block;index" json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Streak is the persisted counter for one streak type of a user
+type Streak struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"uniqueIndex:idx_user_streak" json:"user_id"`
+	Type           string     `gorm:"uniqueIndex:idx_user_streak" json:"type"` // "workout", "food_log", "calorie_target" or "water"
+	Current        int        `json:"current"`
+	Longest        int        `json:"longest"`
+	LastActiveDate *time.Time `gorm:"type:date" json:"last_active_date,omitempty"`
+	FreezeTokens   int        `json:"freeze_tokens"` // Each token covers one missed day
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// StreakDay marks a day that counted towards a streak, in the user's time zone
+type StreakDay struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"uniqueIndex:idx_streak_day" json:"user_id"`
+	Type       string    `gorm:"uniqueIndex:idx_streak_day" json:"type"`
+	Date       time.Time `gorm:"type:date;uniqueIndex:idx_streak_day" json:"date"`
+	Frozen     bool      `json:"frozen"`     // Covered by a freeze token instead of activity
+	Backfilled bool      `json:"backfilled"` // Logged after a later day, only shown in the calendar
+}
@@ -7,6 +7,7 @@ import (
 type User struct {
 	ID                 uint      `gorm:"primaryKey" json:"id"`
 	FullName           string    `json:"full_name"`
+	Username           *string   `gorm:"uniqueIndex" json:"username,omitempty"` // Handle for @mentions, lowercase
 	Height             float64   `json:"height"`
 	Weight             float64   `json:"weight"`
 	Age                int       `json:"age"`
@@ -14,8 +15,15 @@ type User struct {
 	ActivityLevel      string    `json:"activity_level"`
 	EatingHabits       string    `json:"eating_habits"`
 	Goal               string    `json:"goal"`
+	TargetWeight       float64   `json:"target_weight,omitempty"` // Reaching it shows up in followers' feeds
 	WaistCircumference float64   `json:"waist_circumference"`
 	Password           string    `json:"password" gorm:"not null"`
+	AvatarURL          string    `json:"avatar_url"`
+	Role               string    `json:"role" gorm:"default:user"`    // "user", "coach", "moderator" or "admin"
+	Timezone           string    `json:"timezone" gorm:"default:UTC"` // IANA name, e.g., "Asia/Tashkent"
+	LeaderboardOptOut  bool      `json:"leaderboard_opt_out"`         // Hide the user from leaderboards
+	WarningCount       int       `json:"warning_count"`
+	SuspendedUntil     time.Time `json:"suspended_until"` // Can't post, comment or react before this time
 	CreatedAt          time.Time `json:"created_at"`
 	UpdatedAt          time.Time `json:"updated_at"`
 	LastUpdated        time.Time `json:"last_updated"` // New field to track last update
@@ -27,6 +35,7 @@ type Progress struct {
 	Weight    float64   `json:"weight"`
 	Date      time.Time `json:"date"`
 	Calories  int       `json:"calories"`
+	Steps     int       `json:"steps,omitempty"`
 	PhotoURL  string    `json:"photo_url,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
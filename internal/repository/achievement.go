@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Catalog keys of the achievements unlocked before the catalog existed, by name
+var legacyAchievementKeys = map[string]string{
+	"5-Day Workout Streak": "workout_streak_5",
+}
+
+// MigrateAchievementKeys gets achievements unlocked before the catalog ready for the unique (user_id, key) index.
+// They were stored without a key and could be unlocked repeatedly: each one gets the key of its catalog entry,
+// or one derived from its name, and only the first unlock is kept. It runs before AutoMigrate creates the index.
+func (r *UserRepository) MigrateAchievementKeys() error {
+	if !r.Db.Migrator().HasTable(&models.Achievement{}) {
+		return nil
+	}
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`ALTER TABLE achievements ADD COLUMN IF NOT EXISTS "key" text`).Error; err != nil {
+			return err
+		}
+		for name, key := range legacyAchievementKeys {
+			err := tx.Exec(`UPDATE achievements SET "key" = ? WHERE COALESCE("key", '') = '' AND name = ?`, key, name).Error
+			if err != nil {
+				return err
+			}
+		}
+		err := tx.Exec(`UPDATE achievements SET "key" = 'legacy_' || trim(both '_' from regexp_replace(lower(name), '[^a-z0-9]+', '_', 'g'))
+			WHERE COALESCE("key", '') = ''`).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`DELETE FROM achievements a USING achievements b
+			WHERE a.user_id = b.user_id AND a."key" = b."key" AND a.id > b.id`).Error
+	})
+}
+
+func (r *UserRepository) GetAchievementDefinitions() ([]models.AchievementDefinition, error) {
+	var definitions []models.AchievementDefinition
+	err := r.Db.Order("criteria asc, threshold asc").Find(&definitions).Error
+	return definitions, err
+}
+
+// SeedAchievementDefinition creates the definition unless one with the same key exists
+func (r *UserRepository) SeedAchievementDefinition(definition *models.AchievementDefinition) error {
+	return r.Db.Where("key = ?", definition.Key).FirstOrCreate(definition).Error
+}
+
+// UnlockAchievement stores the achievement once per user.
+// It returns false if the user had already unlocked it.
+func (r *UserRepository) UnlockAchievement(achievement *models.Achievement) (bool, error) {
+	result := r.Db.Clauses(clause.OnConflict{DoNothing: true}).Create(achievement)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *UserRepository) CountCompletions(userID uint, itemType string) (int64, error) {
+	var count int64
+	err := r.Db.Model(&models.Completion{}).Where("user_id = ? AND item_type = ?", userID, itemType).Count(&count).Error
+	return count, err
+}
+
+func (r *UserRepository) CountPosts(userID uint) (int64, error) {
+	var count int64
+	err := r.Db.Model(&models.Post{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// WeightLost compares the first and the latest logged weight
+func (r *UserRepository) WeightLost(userID uint) (float64, error) {
+	var first, last []models.Progress
+	if err := r.Db.Where("user_id = ? AND weight > 0", userID).Order("date asc").Limit(1).Find(&first).Error; err != nil {
+		return 0, err
+	}
+	if err := r.Db.Where("user_id = ? AND weight > 0", userID).Order("date desc").Limit(1).Find(&last).Error; err != nil {
+		return 0, err
+	}
+	if len(first) == 0 || len(last) == 0 {
+		return 0, nil
+	}
+	return first[0].Weight - last[0].Weight, nil
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// CreateChallenge stores the challenge and joins its creator to it
+func (r *UserRepository) CreateChallenge(challenge *models.Challenge) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(challenge).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Create(&models.ChallengeParticipant{
+			ChallengeID: challenge.ID,
+			UserID:      challenge.CreatorID,
+			Status:      "joined",
+			JoinedAt:    &now,
+		}).Error
+	})
+}
+
+func (r *UserRepository) FindChallenge(challengeID uint) (*models.Challenge, error) {
+	var challenge models.Challenge
+	err := r.Db.First(&challenge, challengeID).Error
+	return &challenge, err
+}
+
+// GetChallenges lists open challenges and the ones the user was invited to or joined
+func (r *UserRepository) GetChallenges(userID uint) ([]models.Challenge, error) {
+	var challenges []models.Challenge
+	err := r.Db.Where("visibility = ? OR id IN (?)", "open",
+		r.Db.Model(&models.ChallengeParticipant{}).Select("challenge_id").Where("user_id = ?", userID)).
+		Order("ends_at desc").Find(&challenges).Error
+	return challenges, err
+}
+
+func (r *UserRepository) FindParticipant(challengeID, userID uint) (*models.ChallengeParticipant, error) {
+	var participant models.ChallengeParticipant
+	err := r.Db.Where("challenge_id = ? AND user_id = ?", challengeID, userID).First(&participant).Error
+	return &participant, err
+}
+
+// GetJoinedStandings lists the joined participants with their names and no score yet, earliest joiner first
+func (r *UserRepository) GetJoinedStandings(challengeID uint) ([]models.ChallengeStanding, error) {
+	standings := []models.ChallengeStanding{}
+	err := r.Db.Model(&models.ChallengeParticipant{}).
+		Select("challenge_participants.user_id, users.full_name").
+		Joins("JOIN users ON users.id = challenge_participants.user_id").
+		Where("challenge_participants.challenge_id = ? AND challenge_participants.status = ?", challengeID, "joined").
+		Order("challenge_participants.joined_at asc").Scan(&standings).Error
+	return standings, err
+}
+
+// InviteToChallenge adds invitations, leaving existing participants untouched
+func (r *UserRepository) InviteToChallenge(challengeID uint, userIDs []uint) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	invites := make([]models.ChallengeParticipant, len(userIDs))
+	for i, id := range userIDs {
+		invites[i] = models.ChallengeParticipant{ChallengeID: challengeID, UserID: id, Status: "invited"}
+	}
+	return r.Db.Clauses(clause.OnConflict{DoNothing: true}).Create(&invites).Error
+}
+
+func (r *UserRepository) SaveParticipant(participant *models.ChallengeParticipant) error {
+	return r.Db.Save(participant).Error
+}
+
+// GetExpiredChallenges returns active challenges whose end has passed
+func (r *UserRepository) GetExpiredChallenges(now time.Time) ([]models.Challenge, error) {
+	var challenges []models.Challenge
+	err := r.Db.Where("status = ? AND ends_at <= ?", "active", now).Find(&challenges).Error
+	return challenges, err
+}
+
+// CloseChallenge marks the challenge closed with its winner.
+// It returns false if another instance has already closed it.
+func (r *UserRepository) CloseChallenge(challengeID uint, winnerID *uint) (bool, error) {
+	result := r.Db.Model(&models.Challenge{}).
+		Where("id = ? AND status = ?", challengeID, "active").
+		Updates(map[string]interface{}{"status": "closed", "winner_id": winnerID})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *UserRepository) CountCompletionsBetween(userID uint, itemType string, from, to time.Time) (int64, error) {
+	var count int64
+	err := r.Db.Model(&models.Completion{}).
+		Where("user_id = ? AND item_type = ? AND date >= ?::date AND date <= ?::date", userID, itemType, from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *UserRepository) SumPointsBetween(userID uint, from, to time.Time) (int64, error) {
+	var total int64
+	err := r.Db.Model(&models.Point{}).
+		Where("user_id = ? AND created_at >= ? AND created_at <= ?", userID, from, to).
+		Select("COALESCE(SUM(points), 0)").Scan(&total).Error
+	return total, err
+}
+
+// CountCompletionsByParticipant counts each joined participant's completions of the item type in the window
+func (r *UserRepository) CountCompletionsByParticipant(challengeID uint, itemType string, from, to time.Time) (map[uint]float64, error) {
+	query := r.Db.Model(&models.Completion{}).
+		Select("user_id, COUNT(*) AS value").
+		Where("item_type = ? AND date >= ?::date AND date <= ?::date", itemType, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	return r.byParticipant(query.Group("user_id"), challengeID)
+}
+
+// SumPointsByParticipant totals each joined participant's points in the window
+func (r *UserRepository) SumPointsByParticipant(challengeID uint, from, to time.Time) (map[uint]float64, error) {
+	query := r.Db.Model(&models.Point{}).
+		Select("user_id, SUM(points) AS value").
+		Where("created_at >= ? AND created_at <= ?", from, to)
+	return r.byParticipant(query.Group("user_id"), challengeID)
+}
+
+// SumStepsByParticipant totals each joined participant's steps in the window
+func (r *UserRepository) SumStepsByParticipant(challengeID uint, from, to time.Time) (map[uint]float64, error) {
+	query := r.Db.Model(&models.Progress{}).
+		Select("user_id, SUM(steps) AS value").
+		Where("date >= ? AND date <= ?", from, to)
+	return r.byParticipant(query.Group("user_id"), challengeID)
+}
+
+// WeightsAtByParticipant returns each joined participant's latest weight logged at or before t
+func (r *UserRepository) WeightsAtByParticipant(challengeID uint, t time.Time) (map[uint]float64, error) {
+	query := r.Db.Model(&models.Progress{}).
+		Select("DISTINCT ON (user_id) user_id, weight AS value").
+		Where("weight > 0 AND date <= ?", t).
+		Order("user_id, date desc")
+	return r.byParticipant(query, challengeID)
+}
+
+// FirstWeightsByParticipant returns each joined participant's earliest weight logged in the window
+func (r *UserRepository) FirstWeightsByParticipant(challengeID uint, from, to time.Time) (map[uint]float64, error) {
+	query := r.Db.Model(&models.Progress{}).
+		Select("DISTINCT ON (user_id) user_id, weight AS value").
+		Where("weight > 0 AND date >= ? AND date <= ?", from, to).
+		Order("user_id, date asc")
+	return r.byParticipant(query, challengeID)
+}
+
+// byParticipant runs a query selecting user_id and value for the challenge's joined participants.
+// Participants without rows are missing from the map.
+func (r *UserRepository) byParticipant(query *gorm.DB, challengeID uint) (map[uint]float64, error) {
+	joined := r.Db.Model(&models.ChallengeParticipant{}).Select("user_id").
+		Where("challenge_id = ? AND status = ?", challengeID, "joined")
+	var rows []struct {
+		UserID uint
+		Value  float64
+	}
+	if err := query.Where("user_id IN (?)", joined).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	values := make(map[uint]float64, len(rows))
+	for _, row := range rows {
+		values[row.UserID] = row.Value
+	}
+	return values, nil
+}
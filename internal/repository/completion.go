@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+func (r *UserRepository) FindCompletionByKey(userID uint, key string) (*models.Completion, error) {
+	var completion models.Completion
+	err := r.Db.Where("user_id = ? AND idempotency_key = ?", userID, key).First(&completion).Error
+	return &completion, err
+}
+
+func (r *UserRepository) FindPoint(pointID uint) (*models.Point, error) {
+	var point models.Point
+	err := r.Db.First(&point, pointID).Error
+	return &point, err
+}
+
+func (r *UserRepository) FindCompletion(userID uint, itemType string, itemID uint, date time.Time) (*models.Completion, error) {
+	var completion models.Completion
+	err := r.Db.Where("user_id = ? AND item_type = ? AND item_id = ? AND date = ?", userID, itemType, itemID, date).First(&completion).Error
+	return &completion, err
+}
+
+// CountCompletionsOn counts the meals and exercises the user completed on the day
+func (r *UserRepository) CountCompletionsOn(userID uint, day time.Time) (int64, error) {
+	var count int64
+	err := r.Db.Model(&models.Completion{}).
+		Where("user_id = ? AND date = ?::date", userID, day.Format("2006-01-02")).Count(&count).Error
+	return count, err
+}
+
+func (r *UserRepository) FindCompletionByID(completionID, userID uint) (*models.Completion, error) {
+	var completion models.Completion
+	err := r.Db.Where("id = ? AND user_id = ?", completionID, userID).First(&completion).Error
+	return &completion, err
+}
+
+// CreateCompletion stores the completion together with its point in one transaction.
+// The point is cut down to what is left of the daily cap, and not stored at all once the cap is reached.
+// It returns the user's XP total before and after the point.
+func (r *UserRepository) CreateCompletion(completion *models.Completion, point *models.Point, pointCap *models.PointCap) (int, int, error) {
+	var before, after int
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		if point != nil && pointCap != nil {
+			if err := r.capPointTx(tx, point, pointCap); err != nil {
+				return err
+			}
+		}
+		if point != nil && point.Points > 0 {
+			total, err := r.AddPointTx(tx, point)
+			if err != nil {
+				return err
+			}
+			completion.PointID = &point.ID
+			before, after = total-point.Points, total
+		}
+		return tx.Create(completion).Error
+	})
+	return before, after, err
+}
+
+// capPointTx lowers the point to what the action may still earn under the cap. The user's row is locked
+// first, so concurrent awards wait for each other instead of all seeing the same earlier total.
+func (r *UserRepository) capPointTx(tx *gorm.DB, point *models.Point, pointCap *models.PointCap) error {
+	if err := tx.Exec("SELECT id FROM users WHERE id = ? FOR UPDATE", point.UserID).Error; err != nil {
+		return err
+	}
+	var earned int
+	err := tx.Model(&models.Point{}).
+		Where("user_id = ? AND action = ? AND created_at >= ?", point.UserID, point.Action, pointCap.Since).
+		Select("COALESCE(SUM(points), 0)").Scan(&earned).Error
+	if err != nil {
+		return err
+	}
+	if remaining := pointCap.Limit - earned; point.Points > remaining {
+		point.Points = max(remaining, 0)
+	}
+	return nil
+}
+
+// DeleteCompletionTx removes the completion and reverses the points it awarded inside tx
+func (r *UserRepository) DeleteCompletionTx(tx *gorm.DB, completion *models.Completion) error {
+	if err := tx.Delete(completion).Error; err != nil {
+		return err
+	}
+	if completion.PointID != nil {
+		_, err := r.DeletePointTx(tx, *completion.PointID)
+		return err
+	}
+	return nil
+}
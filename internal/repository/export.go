@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"time"
+)
+
+func (r *UserRepository) CreateExportJob(job *models.ExportJob) error {
+	return r.Db.Create(job).Error
+}
+
+func (r *UserRepository) UpdateExportJob(job *models.ExportJob) error {
+	return r.Db.Save(job).Error
+}
+
+func (r *UserRepository) FindExportJob(jobID uint) (*models.ExportJob, error) {
+	var job models.ExportJob
+	err := r.Db.First(&job, jobID).Error
+	return &job, err
+}
+
+// CurrentExportJob returns the user's newest export that is still queued, running or downloadable,
+// or nil if there is none
+func (r *UserRepository) CurrentExportJob(userID uint, now time.Time) (*models.ExportJob, error) {
+	var jobs []models.ExportJob
+	err := r.Db.Where("user_id = ? AND (status IN ? OR (status = ? AND expires_at > ?))",
+		userID, []string{"pending", "running"}, "ready", now).
+		Order("id desc").Limit(1).Find(&jobs).Error
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+// ClaimExportJob marks the oldest pending job as running and returns it, or nil if there is nothing to do.
+// Jobs left running since before staleBefore were interrupted, e.g. by a restart, and are claimed again.
+// SKIP LOCKED lets several instances claim jobs at once without taking the same one.
+func (r *UserRepository) ClaimExportJob(now, staleBefore time.Time) (*models.ExportJob, error) {
+	var jobs []models.ExportJob
+	err := r.Db.Raw(`UPDATE export_jobs SET status = 'running', updated_at = ?
+		WHERE id = (
+			SELECT id FROM export_jobs
+			WHERE status = 'pending' OR (status = 'running' AND updated_at < ?)
+			ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED
+		) RETURNING *`, now, staleBefore).Scan(&jobs).Error
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+// TouchExportJob tells other workers that the running job is still being built
+func (r *UserRepository) TouchExportJob(jobID uint, now time.Time) error {
+	return r.Db.Model(&models.ExportJob{}).Where("id = ? AND status = ?", jobID, "running").
+		UpdateColumn("updated_at", now).Error
+}
+
+func (r *UserRepository) GetExpiredExportJobs(now time.Time) ([]models.ExportJob, error) {
+	var jobs []models.ExportJob
+	err := r.Db.Where("status = ? AND expires_at <= ?", "ready", now).Find(&jobs).Error
+	return jobs, err
+}
+
+func (r *UserRepository) GetProgressRecords(userID uint) ([]models.Progress, error) {
+	progress := []models.Progress{}
+	err := r.Db.Where("user_id = ?", userID).Order("date asc, id asc").Find(&progress).Error
+	return progress, err
+}
+
+// GetLoggedItems lists the user's completed meals or exercises, oldest first
+func (r *UserRepository) GetLoggedItems(userID uint, itemType string) ([]models.LoggedItem, error) {
+	items := []models.LoggedItem{}
+	q := r.Db.Table("completions c").Where("c.user_id = ? AND c.item_type = ?", userID, itemType)
+	if itemType == "meal" {
+		q = q.Select("c.id, c.date, c.item_type, c.item_id, m.name, m.meal_type, m.calories, m.protein, m.carbs, m.fat, c.created_at").
+			Joins("LEFT JOIN meals m ON m.id = c.item_id")
+	} else {
+		q = q.Select("c.id, c.date, c.item_type, c.item_id, e.name, e.duration, e.calories_burned, c.created_at").
+			Joins("LEFT JOIN exercises e ON e.id = c.item_id")
+	}
+	err := q.Order("c.date asc, c.id asc").Scan(&items).Error
+	return items, err
+}
+
+// GetAllReminders lists the user's reminders, disabled ones included
+func (r *UserRepository) GetAllReminders(userID uint) ([]models.Reminder, error) {
+	reminders := []models.Reminder{}
+	err := r.Db.Where("user_id = ?", userID).Order("id asc").Find(&reminders).Error
+	return reminders, err
+}
+
+// GetUserPosts lists every post the user wrote, hidden and group posts included
+func (r *UserRepository) GetUserPosts(userID uint) ([]models.Post, error) {
+	posts := []models.Post{}
+	err := r.Db.Where("user_id = ?", userID).Order("id asc").Find(&posts).Error
+	return posts, err
+}
+
+// GetUserComments lists every comment the user wrote, deleted and hidden ones included
+func (r *UserRepository) GetUserComments(userID uint) ([]models.Comment, error) {
+	comments := []models.Comment{}
+	err := r.Db.Where("user_id = ?", userID).Order("id asc").Find(&comments).Error
+	return comments, err
+}
+
+// GetUserMessages lists every direct message the user sent or received, oldest first
+func (r *UserRepository) GetUserMessages(userID uint) ([]models.Message, error) {
+	messages := []models.Message{}
+	err := r.Db.Where("sender_id = ? OR recipient_id = ?", userID, userID).Order("id asc").Find(&messages).Error
+	return messages, err
+}
+
+// GetGroupMemberships lists the user's memberships, join requests and invitations with the group names
+func (r *UserRepository) GetGroupMemberships(userID uint) ([]models.GroupMembership, error) {
+	memberships := []models.GroupMembership{}
+	err := r.Db.Table("group_members m").
+		Select("m.group_id, g.name AS group_name, m.role, m.status, m.created_at, m.joined_at").
+		Joins("JOIN groups g ON g.id = m.group_id").
+		Where("m.user_id = ?", userID).Order("m.id asc").Scan(&memberships).Error
+	return memberships, err
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"gorm.io/gorm"
+)
+
+func (r *UserRepository) CreateActivity(activity *models.Activity) error {
+	return r.Db.Create(activity).Error
+}
+
+// CreateActivityWithFanOut stores the activity and copies it into the feeds of the author and their followers
+func (r *UserRepository) CreateActivityWithFanOut(activity *models.Activity) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(activity).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`INSERT INTO feed_entries (user_id, activity_id, created_at)
+			SELECT follower_id, ?::bigint, ?::timestamptz FROM follows WHERE following_id = ?
+			UNION SELECT ?::bigint, ?::bigint, ?::timestamptz
+			ON CONFLICT DO NOTHING`,
+			activity.ID, activity.CreatedAt, activity.UserID,
+			activity.UserID, activity.ID, activity.CreatedAt).Error
+	})
+}
+
+// feedItems selects activities with their author's name and avatar, newest first
+func (r *UserRepository) feedItems(beforeID uint, limit int) *gorm.DB {
+	q := r.Db.Model(&models.Activity{}).
+		Select("activities.*, users.full_name AS author_name, users.avatar_url AS author_avatar").
+		Joins("JOIN users ON users.id = activities.user_id")
+	if beforeID != 0 {
+		q = q.Where("activities.id < ?", beforeID)
+	}
+	return q.Order("activities.id DESC").Limit(limit)
+}
+
+// GetFollowedActivities builds the home feed on read from the activities of the user and everyone they follow
+func (r *UserRepository) GetFollowedActivities(userID, beforeID uint, limit int) ([]models.FeedItem, error) {
+	var items []models.FeedItem
+	err := r.feedItems(beforeID, limit).
+		Where("activities.user_id = ? OR activities.user_id IN (?)", userID,
+			r.Db.Model(&models.Follow{}).Select("following_id").Where("follower_id = ?", userID)).
+		Find(&items).Error
+	return items, err
+}
+
+// GetFeedEntries reads the home feed that was fanned out on write
+func (r *UserRepository) GetFeedEntries(userID, beforeID uint, limit int) ([]models.FeedItem, error) {
+	var items []models.FeedItem
+	err := r.feedItems(beforeID, limit).
+		Joins("JOIN feed_entries ON feed_entries.activity_id = activities.id").
+		Where("feed_entries.user_id = ?", userID).
+		Find(&items).Error
+	return items, err
+}
+
+// BackfillFeed copies the latest activities of a newly followed user into the follower's feed
+func (r *UserRepository) BackfillFeed(followerID, followingID uint, limit int) error {
+	return r.Db.Exec(`INSERT INTO feed_entries (user_id, activity_id, created_at)
+		SELECT ?::bigint, id, created_at FROM activities WHERE user_id = ? ORDER BY id DESC LIMIT ?
+		ON CONFLICT DO NOTHING`, followerID, followingID, limit).Error
+}
+
+// RemoveFromFeed takes an unfollowed user's activities out of the follower's feed
+func (r *UserRepository) RemoveFromFeed(followerID, followingID uint) error {
+	return r.Db.Where("user_id = ? AND activity_id IN (?)", followerID,
+		r.Db.Model(&models.Activity{}).Select("id").Where("user_id = ?", followingID)).
+		Delete(&models.FeedEntry{}).Error
+}
+
+// deleteActivities removes the activities about a deleted object from every feed
+func deleteActivities(tx *gorm.DB, activityType string, refID uint) error {
+	ids := tx.Model(&models.Activity{}).Select("id").Where("type = ? AND ref_id = ?", activityType, refID)
+	if err := tx.Where("activity_id IN (?)", ids).Delete(&models.FeedEntry{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("type = ? AND ref_id = ?", activityType, refID).Delete(&models.Activity{}).Error
+}
+
+// MaxSteps returns the user's best daily steps, not counting the given progress entry
+func (r *UserRepository) MaxSteps(userID, excludeID uint) (int, error) {
+	var steps int
+	err := r.Db.Model(&models.Progress{}).
+		Where("user_id = ? AND id <> ?", userID, excludeID).
+		Select("COALESCE(MAX(steps), 0)").Scan(&steps).Error
+	return steps, err
+}
+
+// PreviousWeight returns the latest weigh-in before the given progress entry, or 0 if there is none
+func (r *UserRepository) PreviousWeight(progress *models.Progress) (float64, error) {
+	var previous []models.Progress
+	err := r.Db.Where("user_id = ? AND id <> ? AND weight > 0 AND date <= ?", progress.UserID, progress.ID, progress.Date).
+		Order("date desc, id desc").Limit(1).Find(&previous).Error
+	if err != nil || len(previous) == 0 {
+		return 0, err
+	}
+	return previous[0].Weight, nil
+}
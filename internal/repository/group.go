@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// CreateGroup stores the group with its creator as the first admin
+func (r *UserRepository) CreateGroup(group *models.Group) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(group).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Create(&models.GroupMember{
+			GroupID:  group.ID,
+			UserID:   group.CreatorID,
+			Role:     "admin",
+			Status:   "member",
+			JoinedAt: &now,
+		}).Error
+	})
+}
+
+func (r *UserRepository) UpdateGroup(group *models.Group) error {
+	return r.Db.Model(group).Select("name", "description", "membership").Updates(group).Error
+}
+
+func (r *UserRepository) FindGroup(groupID uint) (*models.Group, error) {
+	var group models.Group
+	err := r.Db.First(&group, groupID).Error
+	return &group, err
+}
+
+// GetGroups lists the groups the user can discover: every open or request group and the groups they belong to or are invited to
+func (r *UserRepository) GetGroups(userID uint, onlyMine bool) ([]models.Group, error) {
+	mine := r.Db.Model(&models.GroupMember{}).Select("group_id").Where("user_id = ?", userID)
+	q := r.Db.Model(&models.Group{})
+	if onlyMine {
+		q = q.Where("id IN (?)", mine.Where("status = ?", "member"))
+	} else {
+		q = q.Where("membership <> ? OR id IN (?)", "invite", mine)
+	}
+	groups := []models.Group{}
+	err := q.Order("name asc").Find(&groups).Error
+	return groups, err
+}
+
+func (r *UserRepository) FindGroupMember(groupID, userID uint) (*models.GroupMember, error) {
+	var member models.GroupMember
+	err := r.Db.Where("group_id = ? AND user_id = ?", groupID, userID).First(&member).Error
+	return &member, err
+}
+
+// IsGroupMember reports whether the user has joined the group, not just requested or been invited
+func (r *UserRepository) IsGroupMember(groupID, userID uint) (bool, error) {
+	var count int64
+	err := r.Db.Model(&models.GroupMember{}).
+		Where("group_id = ? AND user_id = ? AND status = ?", groupID, userID, "member").
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetGroupMembers lists the group's rows with the given status together with the users' names
+func (r *UserRepository) GetGroupMembers(groupID uint, status string) ([]models.GroupMember, error) {
+	members := []models.GroupMember{}
+	err := r.Db.Model(&models.GroupMember{}).
+		Select("group_members.*, users.full_name").
+		Joins("JOIN users ON users.id = group_members.user_id").
+		Where("group_members.group_id = ? AND group_members.status = ?", groupID, status).
+		Order("group_members.role asc, group_members.created_at asc").
+		Find(&members).Error
+	return members, err
+}
+
+// GroupMemberIDs returns the joined members of the group
+func (r *UserRepository) GroupMemberIDs(groupID uint) ([]uint, error) {
+	var ids []uint
+	err := r.Db.Model(&models.GroupMember{}).
+		Where("group_id = ? AND status = ?", groupID, "member").
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+func (r *UserRepository) SaveGroupMember(member *models.GroupMember) error {
+	return r.Db.Save(member).Error
+}
+
+func (r *UserRepository) DeleteGroupMember(member *models.GroupMember) error {
+	return r.Db.Delete(member).Error
+}
+
+// InviteToGroup invites users who have no membership row yet and approves pending requests of the others.
+// Newly invited users get an inbox notification, which is returned for publishing.
+func (r *UserRepository) InviteToGroup(groupID, actorID uint, userIDs []uint, text string) ([]models.Notification, error) {
+	var notifications []models.Notification
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.GroupMember{}).
+			Where("group_id = ? AND user_id IN ? AND status = ?", groupID, userIDs, "requested").
+			Updates(map[string]interface{}{"status": "member", "joined_at": now}).Error; err != nil {
+			return err
+		}
+		for _, userID := range userIDs {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&models.GroupMember{GroupID: groupID, UserID: userID, Role: "member", Status: "invited"})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue // Already invited, requested or a member
+			}
+
+			notification := models.Notification{
+				UserID:  userID,
+				ActorID: actorID,
+				Type:    "group_invite",
+				GroupID: &groupID,
+				Text:    text,
+			}
+			if err := tx.Create(&notification).Error; err != nil {
+				return err
+			}
+			notifications = append(notifications, notification)
+		}
+		return nil
+	})
+	return notifications, err
+}
+
+func (r *UserRepository) CountGroupAdmins(groupID uint) (int64, error) {
+	var count int64
+	err := r.Db.Model(&models.GroupMember{}).
+		Where("group_id = ? AND role = ? AND status = ?", groupID, "admin", "member").
+		Count(&count).Error
+	return count, err
+}
+
+// memberGroups selects the IDs of the groups the user has joined
+func (r *UserRepository) memberGroups(userID uint) *gorm.DB {
+	return r.Db.Model(&models.GroupMember{}).Select("group_id").Where("user_id = ? AND status = ?", userID, "member")
+}
+
+// CanViewPost reports whether the user may see the post. Group posts are for members only.
+func (r *UserRepository) CanViewPost(userID uint, post *models.Post) (bool, error) {
+	if post.GroupID == nil {
+		return true, nil
+	}
+	return r.IsGroupMember(*post.GroupID, userID)
+}
+
+// GetGroupRankings ranks groups by their joined members' points in a leaderboard window.
+// Members who opted out of leaderboards still count towards their group's total.
+// Invite-only groups are left out unless the viewer has a membership row, as in GetGroups.
+func (r *UserRepository) GetGroupRankings(viewerID uint, period string, start time.Time, limit, offset int) ([]models.GroupRanking, int64, error) {
+	ranked := r.Db.Table("groups AS g").
+		Select("RANK() OVER (ORDER BY COALESCE(SUM(a.points), 0) DESC) AS rank, g.id AS group_id, g.name, COUNT(m.id) AS members, COALESCE(SUM(a.points), 0) AS points").
+		Joins("JOIN group_members m ON m.group_id = g.id AND m.status = ?", "member").
+		Joins("LEFT JOIN point_aggregates a ON a.user_id = m.user_id AND a.period = ? AND a.period_start = ?", period, start).
+		Where("g.membership <> ? OR g.id IN (?)", "invite", r.Db.Model(&models.GroupMember{}).Select("group_id").Where("user_id = ?", viewerID)).
+		Group("g.id, g.name")
+
+	var total int64
+	if err := r.Db.Table("(?) AS ranked", ranked).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	rankings := []models.GroupRanking{}
+	err := r.Db.Table("(?) AS ranked", ranked).
+		Order("rank asc, group_id asc").Limit(limit).Offset(offset).Scan(&rankings).Error
+	return rankings, total, err
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var leaderboardPeriods = []string{"week", "month", "all"}
+
+// PeriodStart returns the start of the leaderboard window that t falls into (UTC)
+func PeriodStart(period string, t time.Time) (time.Time, error) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case "week":
+		offset := (int(day.Weekday()) + 6) % 7 // Weeks start on Monday, like date_trunc('week')
+		return day.AddDate(0, 0, -offset), nil
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	case "all":
+		return time.Unix(0, 0).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown period %q", period)
+}
+
+// addToAggregates applies delta to every leaderboard window of the moment the points were earned
+func (r *UserRepository) addToAggregates(tx *gorm.DB, userID uint, delta int, at time.Time) error {
+	for _, period := range leaderboardPeriods {
+		start, _ := PeriodStart(period, at)
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "period"}, {Name: "period_start"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"points": gorm.Expr("point_aggregates.points + ?", delta)}),
+		}).Create(&models.PointAggregate{UserID: userID, Period: period, PeriodStart: start, Points: delta}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RebuildPointAggregates fills the aggregates from the points table when they are empty,
+// e.g. on the first start after leaderboards were added
+func (r *UserRepository) RebuildPointAggregates() error {
+	var count int64
+	if err := r.Db.Model(&models.PointAggregate{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+	return r.Db.Exec(`INSERT INTO point_aggregates (user_id, period, period_start, points)
+		SELECT user_id, 'week', date_trunc('week', created_at AT TIME ZONE 'UTC')::date, SUM(points) FROM points GROUP BY 1, 3
+		UNION ALL
+		SELECT user_id, 'month', date_trunc('month', created_at AT TIME ZONE 'UTC')::date, SUM(points) FROM points GROUP BY 1, 3
+		UNION ALL
+		SELECT user_id, 'all', DATE '1970-01-01', SUM(points) FROM points GROUP BY 1`).Error
+}
+
+// rankedQuery selects the ranked leaderboard rows of a window. A nil userIDs means every user.
+func (r *UserRepository) rankedQuery(period string, start time.Time, userIDs []uint) *gorm.DB {
+	q := r.Db.Table("point_aggregates AS a").
+		Select("RANK() OVER (ORDER BY a.points DESC) AS rank, a.user_id, u.full_name, a.points").
+		Joins("JOIN users u ON u.id = a.user_id").
+		Where("a.period = ? AND a.period_start = ? AND u.leaderboard_opt_out = ?", period, start, false)
+	if userIDs != nil {
+		q = q.Where("a.user_id IN ?", userIDs)
+	}
+	return q
+}
+
+func (r *UserRepository) GetLeaderboard(period string, start time.Time, userIDs []uint, limit, offset int) ([]models.LeaderboardEntry, int64, error) {
+	var total int64
+	if err := r.Db.Table("(?) AS ranked", r.rankedQuery(period, start, userIDs)).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []models.LeaderboardEntry
+	err := r.Db.Table("(?) AS ranked", r.rankedQuery(period, start, userIDs)).
+		Order("rank asc, user_id asc").Limit(limit).Offset(offset).Scan(&entries).Error
+	return entries, total, err
+}
+
+// GetLeaderboardEntry returns the user's own row, or nil if the user isn't ranked
+func (r *UserRepository) GetLeaderboardEntry(period string, start time.Time, userIDs []uint, userID uint) (*models.LeaderboardEntry, error) {
+	var entries []models.LeaderboardEntry
+	err := r.Db.Table("(?) AS ranked", r.rankedQuery(period, start, userIDs)).
+		Where("user_id = ?", userID).Limit(1).Scan(&entries).Error
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+func (r *UserRepository) SetLeaderboardOptOut(userID uint, optOut bool) error {
+	return r.Db.Model(&models.User{}).Where("id = ?", userID).Update("leaderboard_opt_out", optOut).Error
+}
+
+func (r *UserRepository) Follow(followerID, followingID uint) error {
+	return r.Db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.Follow{FollowerID: followerID, FollowingID: followingID}).Error
+}
+
+func (r *UserRepository) Unfollow(followerID, followingID uint) error {
+	return r.Db.Where("follower_id = ? AND following_id = ?", followerID, followingID).Delete(&models.Follow{}).Error
+}
+
+// GetFollows lists the followers of the user, or the users they follow
+func (r *UserRepository) GetFollows(userID uint, followers bool) ([]models.UserSummary, error) {
+	join, where := "JOIN follows ON follows.following_id = users.id", "follows.follower_id = ?"
+	if followers {
+		join, where = "JOIN follows ON follows.follower_id = users.id", "follows.following_id = ?"
+	}
+	users := []models.UserSummary{}
+	err := r.Db.Model(&models.User{}).Select("users.id, users.full_name, users.avatar_url").
+		Joins(join).Where(where, userID).
+		Order("follows.created_at DESC").Scan(&users).Error
+	return users, err
+}
+
+// FriendIDs returns the users that follow userID back
+func (r *UserRepository) FriendIDs(userID uint) ([]uint, error) {
+	var ids []uint
+	err := r.Db.Table("follows AS f1").
+		Joins("JOIN follows f2 ON f2.follower_id = f1.following_id AND f2.following_id = f1.follower_id").
+		Where("f1.follower_id = ?", userID).
+		Pluck("f1.following_id", &ids).Error
+	return ids, err
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"gorm.io/gorm"
+)
+
+// AddPointTx creates the point and adds it to the user's cached XP and leaderboard aggregates inside tx.
+// It returns the new XP total.
+func (r *UserRepository) AddPointTx(tx *gorm.DB, point *models.Point) (int, error) {
+	if err := tx.Create(point).Error; err != nil {
+		return 0, err
+	}
+	if err := r.addToAggregates(tx, point.UserID, point.Points, point.CreatedAt); err != nil {
+		return 0, err
+	}
+	return r.addXP(tx, point.UserID, point.Points)
+}
+
+// DeletePointTx deletes the point and subtracts it from the user's cached XP and leaderboard aggregates inside tx.
+// It returns the new XP total.
+func (r *UserRepository) DeletePointTx(tx *gorm.DB, pointID uint) (int, error) {
+	var point models.Point
+	if err := tx.First(&point, pointID).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Delete(&point).Error; err != nil {
+		return 0, err
+	}
+	if err := r.addToAggregates(tx, point.UserID, -point.Points, point.CreatedAt); err != nil {
+		return 0, err
+	}
+	return r.addXP(tx, point.UserID, -point.Points)
+}
+
+// GetXP returns the cached XP total, building the cache from the points table the first time
+func (r *UserRepository) GetXP(userID uint) (int, error) {
+	var xp []models.UserXP
+	if err := r.Db.Where("user_id = ?", userID).Limit(1).Find(&xp).Error; err != nil {
+		return 0, err
+	}
+	if len(xp) > 0 {
+		return xp[0].TotalXP, nil
+	}
+
+	var total int
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Point{}).Where("user_id = ?", userID).Select("COALESCE(SUM(points), 0)").Scan(&total).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec("INSERT INTO user_xps (user_id, total_xp, updated_at) VALUES (?, ?, NOW()) ON CONFLICT (user_id) DO NOTHING", userID, total).Error
+	})
+	return total, err
+}
+
+// SpendPointsTx takes amount from the user's spendable balance, the XP total minus what was spent before,
+// inside tx. XP, levels and leaderboards are left alone. It returns false if the balance is too low.
+func (r *UserRepository) SpendPointsTx(tx *gorm.DB, userID uint, amount int) (bool, error) {
+	if _, err := r.addXP(tx, userID, 0); err != nil {
+		return false, err
+	}
+	result := tx.Model(&models.UserXP{}).
+		Where("user_id = ? AND total_xp - spent_points >= ?", userID, amount).
+		Updates(map[string]interface{}{"spent_points": gorm.Expr("spent_points + ?", amount), "updated_at": gorm.Expr("NOW()")})
+	return result.RowsAffected == 1, result.Error
+}
+
+// GetSpentPoints returns how many points the user has spent
+func (r *UserRepository) GetSpentPoints(userID uint) (int, error) {
+	var xp []models.UserXP
+	if err := r.Db.Where("user_id = ?", userID).Limit(1).Find(&xp).Error; err != nil || len(xp) == 0 {
+		return 0, err
+	}
+	return xp[0].SpentPoints, nil
+}
+
+// addXP applies delta to the cached total. Without a cache row yet, the total is
+// built from the points table, which already reflects the change made in tx.
+func (r *UserRepository) addXP(tx *gorm.DB, userID uint, delta int) (int, error) {
+	var total int
+	err := tx.Raw(`INSERT INTO user_xps (user_id, total_xp, updated_at)
+		VALUES (?, (SELECT COALESCE(SUM(points), 0) FROM points WHERE user_id = ?), NOW())
+		ON CONFLICT (user_id) DO UPDATE SET total_xp = user_xps.total_xp + ?, updated_at = NOW()
+		RETURNING total_xp`, userID, userID, delta).Scan(&total).Error
+	return total, err
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// FindOrCreateConversation returns the conversation between the two users, creating it on first contact
+func (r *UserRepository) FindOrCreateConversation(userID, peerID uint) (*models.Conversation, error) {
+	low, high := userID, peerID
+	if low > high {
+		low, high = high, low
+	}
+	conversation := models.Conversation{UserLowID: low, UserHighID: high, LastMessageAt: time.Now()}
+	if err := r.Db.Clauses(clause.OnConflict{DoNothing: true}).Create(&conversation).Error; err != nil {
+		return nil, err
+	}
+	err := r.Db.Where("user_low_id = ? AND user_high_id = ?", low, high).First(&conversation).Error
+	return &conversation, err
+}
+
+func (r *UserRepository) FindConversation(conversationID uint) (*models.Conversation, error) {
+	var conversation models.Conversation
+	err := r.Db.First(&conversation, conversationID).Error
+	return &conversation, err
+}
+
+// CreateMessage stores the message and moves its conversation to the top of both users' lists
+func (r *UserRepository) CreateMessage(message *models.Message) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Conversation{}).Where("id = ?", message.ConversationID).
+			Update("last_message_at", message.CreatedAt).Error
+	})
+}
+
+// GetConversations lists the user's conversations, most recent first, with the last message and unread count of each
+func (r *UserRepository) GetConversations(userID uint, limit, offset int) ([]models.ConversationSummary, error) {
+	conversations := []models.ConversationSummary{}
+	err := r.Db.Table("conversations AS c").
+		Select("c.id, c.last_message_at, u.id AS peer_id, u.full_name AS peer_name, u.avatar_url AS peer_avatar, u.role AS peer_role, "+
+			"lm.content AS last_message, lm.sender_id AS last_sender_id, "+
+			"(SELECT COUNT(*) FROM messages m WHERE m.conversation_id = c.id AND m.recipient_id = ? AND m.read_at IS NULL) AS unread_count", userID).
+		Joins("JOIN users u ON u.id = CASE WHEN c.user_low_id = ? THEN c.user_high_id ELSE c.user_low_id END", userID).
+		Joins("LEFT JOIN LATERAL (SELECT content, sender_id FROM messages WHERE conversation_id = c.id ORDER BY id DESC LIMIT 1) lm ON true").
+		Where("c.user_low_id = ? OR c.user_high_id = ?", userID, userID).
+		Order("c.last_message_at DESC, c.id DESC").Limit(limit).Offset(offset).
+		Scan(&conversations).Error
+	return conversations, err
+}
+
+// GetMessages returns up to limit messages of the conversation older than the message beforeID
+// (every message when beforeID is 0), newest first
+func (r *UserRepository) GetMessages(conversationID, beforeID uint, limit int) ([]models.Message, error) {
+	q := r.Db.Where("conversation_id = ?", conversationID)
+	if beforeID != 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	messages := []models.Message{}
+	err := q.Order("id DESC").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+// MarkMessagesRead sets the read receipt on the reader's unread messages of the conversation and returns how many there were
+func (r *UserRepository) MarkMessagesRead(conversationID, readerID uint, at time.Time) (int64, error) {
+	result := r.Db.Model(&models.Message{}).
+		Where("conversation_id = ? AND recipient_id = ? AND read_at IS NULL", conversationID, readerID).
+		Update("read_at", at)
+	return result.RowsAffected, result.Error
+}
+
+func (r *UserRepository) CountUnreadMessages(userID uint) (int64, error) {
+	var count int64
+	err := r.Db.Model(&models.Message{}).Where("recipient_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// IsBlockedBetween reports whether either user has blocked the other
+func (r *UserRepository) IsBlockedBetween(userID, peerID uint) (bool, error) {
+	var count int64
+	err := r.Db.Model(&models.Block{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", userID, peerID, peerID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
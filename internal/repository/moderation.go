@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+func (r *UserRepository) CreateReport(report *models.Report) error {
+	return r.Db.Create(report).Error
+}
+
+func (r *UserRepository) FindReport(reportID uint) (*models.Report, error) {
+	var report models.Report
+	err := r.Db.First(&report, reportID).Error
+	return &report, err
+}
+
+// GetReportQueue groups the open reports by their target, most reported first
+func (r *UserRepository) GetReportQueue(limit, offset int) ([]models.ReportQueueItem, error) {
+	var items []models.ReportQueueItem
+	err := r.Db.Model(&models.Report{}).
+		Select("target_type, target_id, COUNT(*) AS report_count, MIN(created_at) AS first_reported_at").
+		Where("status = ?", "open").
+		Group("target_type, target_id").
+		Order("report_count DESC, first_reported_at ASC").
+		Limit(limit).Offset(offset).
+		Scan(&items).Error
+	return items, err
+}
+
+func (r *UserRepository) GetOpenReports(targetType string, targetID uint) ([]models.Report, error) {
+	var reports []models.Report
+	err := r.Db.Where("status = ? AND target_type = ? AND target_id = ?", "open", targetType, targetID).
+		Order("created_at asc").Find(&reports).Error
+	return reports, err
+}
+
+func (r *UserRepository) AddModerationAction(action *models.ModerationAction) error {
+	return r.Db.Create(action).Error
+}
+
+// Moderate carries out the action, closes the target's open reports and writes the audit entry in one transaction
+func (r *UserRepository) Moderate(action *models.ModerationAction) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var err error
+		switch action.Action {
+		case "hide":
+			err = tx.Table(contentTable(action.TargetType)).Where("id = ?", action.TargetID).Update("hidden_at", now).Error
+		case "unhide":
+			err = tx.Table(contentTable(action.TargetType)).Where("id = ?", action.TargetID).Update("hidden_at", nil).Error
+		case "delete":
+			if action.TargetType == "post" {
+				err = deletePostTx(tx, action.TargetID)
+			} else {
+				err = tx.Model(&models.Comment{}).Where("id = ?", action.TargetID).
+					Updates(map[string]interface{}{"content": "", "deleted_at": now}).Error
+				if err == nil {
+					err = deleteTagUsesTx(tx, "comment", action.TargetID)
+				}
+			}
+		case "warn":
+			err = tx.Model(&models.User{}).Where("id = ?", action.UserID).
+				Update("warning_count", gorm.Expr("warning_count + 1")).Error
+		case "suspend":
+			err = tx.Model(&models.User{}).Where("id = ?", action.UserID).Update("suspended_until", *action.Until).Error
+		case "dismiss":
+		default:
+			return fmt.Errorf("unknown moderation action %q", action.Action)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Any decision about reported content settles its reports, except undoing a hide
+		if action.TargetType != "user" && action.Action != "unhide" {
+			status := "resolved"
+			if action.Action == "dismiss" {
+				status = "dismissed"
+			}
+			err := tx.Model(&models.Report{}).
+				Where("status = ? AND target_type = ? AND target_id = ?", "open", action.TargetType, action.TargetID).
+				Updates(map[string]interface{}{"status": status, "resolved_at": now}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Create(action).Error
+	})
+}
+
+// SetRole changes the user's role and records who did it
+func (r *UserRepository) SetRole(userID uint, role string, audit *models.ModerationAction) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("role", role).Error; err != nil {
+			return err
+		}
+		return tx.Create(audit).Error
+	})
+}
+
+func contentTable(targetType string) string {
+	if targetType == "post" {
+		return "posts"
+	}
+	return "comments"
+}
+
+// GetModerationActions returns the audit log, newest first, optionally for one target or moderator
+func (r *UserRepository) GetModerationActions(targetType string, targetID, moderatorID uint, limit, offset int) ([]models.ModerationAction, error) {
+	q := r.Db.Model(&models.ModerationAction{})
+	if targetType != "" {
+		q = q.Where("target_type = ? AND target_id = ?", targetType, targetID)
+	}
+	if moderatorID != 0 {
+		q = q.Where("moderator_id = ?", moderatorID)
+	}
+	var actions []models.ModerationAction
+	err := q.Order("created_at desc, id desc").Limit(limit).Offset(offset).Find(&actions).Error
+	return actions, err
+}
+
+// GetWarnings lists the warnings and suspensions a user received, without who issued them
+func (r *UserRepository) GetWarnings(userID uint) ([]models.ModerationAction, error) {
+	actions := []models.ModerationAction{}
+	err := r.Db.Omit("moderator_id").Where("user_id = ? AND action IN ?", userID, []string{"warn", "suspend"}).
+		Order("created_at desc").Find(&actions).Error
+	return actions, err
+}
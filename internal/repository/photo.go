@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+func (r *UserRepository) CreateProgressPhoto(photo *models.ProgressPhoto) error {
+	return r.Db.Create(photo).Error
+}
+
+func (r *UserRepository) FindProgressPhoto(photoID uint) (*models.ProgressPhoto, error) {
+	var photo models.ProgressPhoto
+	err := r.Db.First(&photo, photoID).Error
+	return &photo, err
+}
+
+// GetProgressPhotos lists the user's photos, newest first. An empty pose means every pose.
+func (r *UserRepository) GetProgressPhotos(userID uint, pose string) ([]models.ProgressPhoto, error) {
+	q := r.Db.Where("user_id = ?", userID)
+	if pose != "" {
+		q = q.Where("pose = ?", pose)
+	}
+	photos := []models.ProgressPhoto{}
+	err := q.Order("date desc, id desc").Find(&photos).Error
+	return photos, err
+}
+
+// NearestProgressPhoto returns the user's photo of the pose taken closest to date, or nil if there is none
+func (r *UserRepository) NearestProgressPhoto(userID uint, pose string, date time.Time) (*models.ProgressPhoto, error) {
+	var photos []models.ProgressPhoto
+	err := r.Db.Where("user_id = ? AND pose = ?", userID, pose).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "ABS(date - ?::date), date asc, id asc", Vars: []interface{}{date.Format("2006-01-02")}}}).
+		Limit(1).Find(&photos).Error
+	if err != nil || len(photos) == 0 {
+		return nil, err
+	}
+	return &photos[0], nil
+}
+
+func (r *UserRepository) DeleteProgressPhoto(photo *models.ProgressPhoto) error {
+	return r.Db.Delete(photo).Error
+}
+
+func (r *UserRepository) AddCoach(coachID, clientID uint) error {
+	return r.Db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.CoachClient{CoachID: coachID, ClientID: clientID}).Error
+}
+
+func (r *UserRepository) RemoveCoach(coachID, clientID uint) error {
+	return r.Db.Where("coach_id = ? AND client_id = ?", coachID, clientID).Delete(&models.CoachClient{}).Error
+}
+
+// IsCoachOf reports whether the client has given the coach access to their progress.
+// The access lapses while the coach doesn't have the coach role.
+func (r *UserRepository) IsCoachOf(coachID, clientID uint) (bool, error) {
+	var count int64
+	err := r.Db.Model(&models.CoachClient{}).
+		Joins("JOIN users ON users.id = coach_clients.coach_id AND users.role = ?", "coach").
+		Where("coach_clients.coach_id = ? AND coach_clients.client_id = ?", coachID, clientID).Count(&count).Error
+	return count > 0, err
+}
+
+// GetCoaches lists the coaches of the client, or the clients of the coach
+func (r *UserRepository) GetCoaches(userID uint, clients bool) ([]models.UserSummary, error) {
+	join, where := "JOIN coach_clients ON coach_clients.coach_id = users.id", "coach_clients.client_id = ?"
+	if clients {
+		join, where = "JOIN coach_clients ON coach_clients.client_id = users.id", "coach_clients.coach_id = ?"
+	}
+	users := []models.UserSummary{}
+	err := r.Db.Model(&models.User{}).Select("users.id, users.full_name, users.avatar_url").
+		Joins(join).Where(where, userID).
+		Order("coach_clients.created_at DESC").Scan(&users).Error
+	return users, err
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+func (r *UserRepository) UpdatePost(post *models.Post) error {
+	return r.Db.Model(post).Select("title", "content").Updates(post).Error
+}
+
+// DeletePost removes the post with its comments, reactions, image rows, tags, mentions and feed activity.
+// Deleting the image files is left to the caller.
+func (r *UserRepository) DeletePost(post *models.Post) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		return deletePostTx(tx, post.ID)
+	})
+}
+
+func deletePostTx(tx *gorm.DB, postID uint) error {
+	commentIDs := tx.Model(&models.Comment{}).Select("id").Where("post_id = ?", postID)
+	if err := tx.Where("target_type = ? AND target_id IN (?)", "comment", commentIDs).Delete(&models.Reaction{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("target_type = ? AND target_id = ?", "post", postID).Delete(&models.Reaction{}).Error; err != nil {
+		return err
+	}
+	// Reports about removed content have nothing left to review
+	if err := tx.Model(&models.Report{}).
+		Where("status = ? AND ((target_type = ? AND target_id = ?) OR (target_type = ? AND target_id IN (?)))", "open", "post", postID, "comment", commentIDs).
+		Updates(map[string]interface{}{"status": "resolved", "resolved_at": time.Now()}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("post_id = ?", postID).Delete(&models.TagUse{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("post_id = ?", postID).Delete(&models.Mention{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("post_id = ?", postID).Delete(&models.Notification{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("post_id = ?", postID).Delete(&models.Comment{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("post_id = ?", postID).Delete(&models.PostImage{}).Error; err != nil {
+		return err
+	}
+	if err := deleteActivities(tx, "post", postID); err != nil {
+		return err
+	}
+	return tx.Delete(&models.Post{}, postID).Error
+}
+
+// GetPostsByIDs loads the visible posts with their authors, in no particular order
+func (r *UserRepository) GetPostsByIDs(postIDs []uint) ([]models.FeedPost, error) {
+	var posts []models.FeedPost
+	if len(postIDs) == 0 {
+		return posts, nil
+	}
+	err := r.Db.Model(&models.Post{}).
+		Select("posts.*, users.full_name AS author_name, users.avatar_url AS author_avatar").
+		Joins("JOIN users ON users.id = posts.user_id").
+		Where("posts.id IN ? AND posts.hidden_at IS NULL", postIDs).Find(&posts).Error
+	return posts, err
+}
+
+// AddPostImage saves the image unless the post already has limit images. The post row is locked
+// while counting, so concurrent uploads cannot go over the limit together.
+func (r *UserRepository) AddPostImage(image *models.PostImage, limit int) (bool, error) {
+	added := false
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT id FROM posts WHERE id = ? FOR UPDATE", image.PostID).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.PostImage{}).Where("post_id = ?", image.PostID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= int64(limit) {
+			return nil
+		}
+		added = true
+		return tx.Create(image).Error
+	})
+	return added && err == nil, err
+}
+
+// GetPostImages loads the images of several posts in one query, grouped by post
+func (r *UserRepository) GetPostImages(postIDs []uint) (map[uint][]models.PostImage, error) {
+	images := make(map[uint][]models.PostImage, len(postIDs))
+	if len(postIDs) == 0 {
+		return images, nil
+	}
+	var rows []models.PostImage
+	err := r.Db.Where("post_id IN ?", postIDs).Order("id").Find(&rows).Error
+	for _, image := range rows {
+		images[image.PostID] = append(images[image.PostID], image)
+	}
+	return images, err
+}
+
+func (r *UserRepository) GetPostImage(postID, imageID uint) (*models.PostImage, error) {
+	var image models.PostImage
+	err := r.Db.Where("id = ? AND post_id = ?", imageID, postID).First(&image).Error
+	return &image, err
+}
+
+func (r *UserRepository) DeletePostImage(image *models.PostImage) error {
+	return r.Db.Delete(image).Error
+}
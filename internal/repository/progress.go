@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"slices"
+	"time"
+)
+
+// seriesSources maps each metric of the progress series to the table and column it is stored in.
+// A value of 0 or NULL means the metric wasn't logged in that entry.
+var seriesSources = map[string]struct{ table, column string }{
+	"weight":   {"progresses", "weight"},
+	"calories": {"progresses", "calories"},
+	"steps":    {"progresses", "steps"},
+
+	"waist":              {"measurements", "waist"},
+	"hips":               {"measurements", "hips"},
+	"chest":              {"measurements", "chest"},
+	"neck":               {"measurements", "neck"},
+	"arms":               {"measurements", "arms"},
+	"thighs":             {"measurements", "thighs"},
+	"body_fat":           {"measurements", "body_fat"},
+	"resting_heart_rate": {"measurements", "resting_heart_rate"},
+}
+
+func IsSeriesMetric(metric string) bool {
+	_, ok := seriesSources[metric]
+	return ok
+}
+
+// GetSeriesBuckets aggregates the metric per day, week or month between from and to, oldest first.
+// The metric and bucket must already be validated.
+func (r *UserRepository) GetSeriesBuckets(userID uint, metric, bucket string, from, to time.Time) ([]models.SeriesBucket, error) {
+	source := seriesSources[metric]
+	buckets := []models.SeriesBucket{}
+	err := r.Db.Table(source.table).
+		Select("date_trunc(?, date) AS start, AVG("+source.column+")::float8 AS avg, MIN("+source.column+")::float8 AS min, MAX("+source.column+")::float8 AS max, COUNT(*) AS count", bucket).
+		Where("user_id = ? AND date >= ? AND date < ? AND "+source.column+" > 0", userID, from, to).
+		Group("start").Order("start asc").
+		Scan(&buckets).Error
+	return buckets, err
+}
+
+// GetSeriesEnds returns the first and last readings of the metric between from and to, or nil when there are none
+func (r *UserRepository) GetSeriesEnds(userID uint, metric string, from, to time.Time) (*models.SeriesReading, *models.SeriesReading, error) {
+	source := seriesSources[metric]
+	q := func(order string) (*models.SeriesReading, error) {
+		var readings []models.SeriesReading
+		err := r.Db.Table(source.table).
+			Select("date, "+source.column+"::float8 AS value").
+			Where("user_id = ? AND date >= ? AND date < ? AND "+source.column+" > 0", userID, from, to).
+			Order("date " + order + ", id " + order).Limit(1).
+			Scan(&readings).Error
+		if err != nil || len(readings) == 0 {
+			return nil, err
+		}
+		return &readings[0], nil
+	}
+	first, err := q("asc")
+	if err != nil || first == nil {
+		return nil, nil, err
+	}
+	last, err := q("desc")
+	return first, last, err
+}
+
+// measurementColumns are the measured fields that a new entry for the same day merges into
+var measurementColumns = []string{"waist", "hips", "chest", "neck", "arms", "thighs", "body_fat", "resting_heart_rate"}
+
+// SaveMeasurement stores the measurement, merging it into an existing entry of the same day.
+// The columns in clear are emptied; an entry left without any value is removed.
+// The user's waist is kept in step with their latest waist measurement.
+func (r *UserRepository) SaveMeasurement(measurement *models.Measurement, clear []string) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		merge := map[string]interface{}{"updated_at": gorm.Expr("EXCLUDED.updated_at")}
+		for _, column := range measurementColumns {
+			merge[column] = gorm.Expr("COALESCE(EXCLUDED." + column + ", measurements." + column + ")")
+		}
+		for _, column := range clear {
+			merge[column] = gorm.Expr("NULL")
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "date"}},
+			DoUpdates: clause.Assignments(merge),
+		}).Create(measurement).Error
+		if err != nil {
+			return err
+		}
+		waistChanged := measurement.Waist != nil || slices.Contains(clear, "waist")
+
+		// Read back the merged entry
+		var merged models.Measurement
+		if err := tx.Where("user_id = ? AND date = ?", measurement.UserID, measurement.Date).First(&merged).Error; err != nil {
+			return err
+		}
+		*measurement = merged
+		empty := tx.Where("id = ?", merged.ID)
+		for _, column := range measurementColumns {
+			empty = empty.Where(column + " IS NULL")
+		}
+		if err := empty.Delete(&models.Measurement{}).Error; err != nil {
+			return err
+		}
+		if !waistChanged {
+			return nil
+		}
+		return syncWaist(tx, measurement.UserID)
+	})
+}
+
+// syncWaist copies the user's latest waist measurement to their profile. Without any, the profile keeps its value.
+func syncWaist(tx *gorm.DB, userID uint) error {
+	var latest []models.Measurement
+	if err := tx.Where("user_id = ? AND waist IS NOT NULL", userID).Order("date desc").Limit(1).Find(&latest).Error; err != nil {
+		return err
+	}
+	if len(latest) == 0 {
+		return nil
+	}
+	return tx.Model(&models.User{}).Where("id = ?", userID).Update("waist_circumference", *latest[0].Waist).Error
+}
+
+// GetMeasurements lists the user's measurements between from and to, newest first
+func (r *UserRepository) GetMeasurements(userID uint, from, to time.Time) ([]models.Measurement, error) {
+	measurements := []models.Measurement{}
+	err := r.Db.Where("user_id = ? AND date >= ? AND date < ?", userID, from, to).
+		Order("date desc").Find(&measurements).Error
+	return measurements, err
+}
+
+func (r *UserRepository) FindMeasurement(measurementID uint) (*models.Measurement, error) {
+	var measurement models.Measurement
+	err := r.Db.First(&measurement, measurementID).Error
+	return &measurement, err
+}
+
+// DeleteMeasurement removes the entry and moves the user's waist back to the latest remaining one
+func (r *UserRepository) DeleteMeasurement(measurement *models.Measurement) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(measurement).Error; err != nil {
+			return err
+		}
+		if measurement.Waist == nil {
+			return nil
+		}
+		return syncWaist(tx, measurement.UserID)
+	})
+}
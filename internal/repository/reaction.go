@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ToggleReaction adds the reaction, switches it to the new type, or removes it when the
+// same type is sent again. It returns the user's reaction afterwards, or nil if removed.
+func (r *UserRepository) ToggleReaction(userID uint, targetType string, targetID uint, reactionType string) (*models.Reaction, error) {
+	var result *models.Reaction
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		var existing models.Reaction
+		err := tx.Where("user_id = ? AND target_type = ? AND target_id = ?", userID, targetType, targetID).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			reaction := models.Reaction{UserID: userID, TargetType: targetType, TargetID: targetID, Type: reactionType}
+			created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction)
+			if created.Error != nil {
+				return created.Error
+			}
+			if created.RowsAffected == 0 {
+				// A concurrent request reacted first, its reaction stands
+				reaction = models.Reaction{}
+				err := tx.Where("user_id = ? AND target_type = ? AND target_id = ?", userID, targetType, targetID).First(&reaction).Error
+				if err != nil {
+					return err
+				}
+			}
+			result = &reaction
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if existing.Type == reactionType {
+			return tx.Delete(&existing).Error
+		}
+		existing.Type = reactionType
+		result = &existing
+		return tx.Save(&existing).Error
+	})
+	return result, err
+}
+
+// GetReactionSummaries counts reactions per type for the targets and marks the user's own reaction
+func (r *UserRepository) GetReactionSummaries(userID uint, targetType string, targetIDs []uint) (map[uint]*models.ReactionSummary, error) {
+	summaries := make(map[uint]*models.ReactionSummary, len(targetIDs))
+	for _, id := range targetIDs {
+		summaries[id] = &models.ReactionSummary{Counts: map[string]int{}}
+	}
+	if len(targetIDs) == 0 {
+		return summaries, nil
+	}
+
+	var rows []struct {
+		TargetID uint
+		Type     string
+		Count    int
+	}
+	err := r.Db.Model(&models.Reaction{}).
+		Select("target_id, type, COUNT(*) AS count").
+		Where("target_type = ? AND target_id IN ?", targetType, targetIDs).
+		Group("target_id, type").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		summaries[row.TargetID].Counts[row.Type] = row.Count
+		summaries[row.TargetID].Total += row.Count
+	}
+
+	var mine []models.Reaction
+	err = r.Db.Where("user_id = ? AND target_type = ? AND target_id IN ?", userID, targetType, targetIDs).Find(&mine).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, reaction := range mine {
+		summaries[reaction.TargetID].MyReaction = reaction.Type
+	}
+	return summaries, nil
+}
+
+func (r *UserRepository) GetCommentByID(commentID uint) (*models.Comment, error) {
+	var comment models.Comment
+	err := r.Db.First(&comment, commentID).Error
+	return &comment, err
+}
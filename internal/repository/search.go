@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"strings"
+	"time"
+)
+
+// searchConfig is the text search configuration for community content. It is based on "simple",
+// which doesn't stem and so treats Uzbek, Russian and English alike, and strips accents with unaccent.
+const searchConfig = "community_search"
+
+// Marks around matched words in headlines, replaced by the caller after HTML escaping
+const (
+	HighlightStart = "[[["
+	HighlightStop  = "]]]"
+)
+
+// MigrateSearch creates the search configuration and the generated tsvector columns with their GIN indexes.
+// It runs after AutoMigrate, since the columns are added to existing tables.
+func (r *UserRepository) MigrateSearch() error {
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS unaccent`,
+		`DO $$ BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = '` + searchConfig + `') THEN
+				CREATE TEXT SEARCH CONFIGURATION ` + searchConfig + ` (COPY = simple);
+				ALTER TEXT SEARCH CONFIGURATION ` + searchConfig + ` ALTER MAPPING FOR hword, hword_part, word WITH unaccent, simple;
+			END IF;
+		END $$`,
+		`ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('` + searchConfig + `', coalesce(title, '')), 'A') ||
+			setweight(to_tsvector('` + searchConfig + `', coalesce(content, '')), 'B')) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_posts_search ON posts USING GIN (search_vector)`,
+		`ALTER TABLE comments ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('` + searchConfig + `', coalesce(content, '')), 'B')) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_search ON comments USING GIN (search_vector)`,
+	}
+	for _, statement := range statements {
+		if err := r.Db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visibleGroupSQL keeps posts outside of groups and in groups the @viewer has joined
+const visibleGroupSQL = `(p.group_id IS NULL OR p.group_id IN (SELECT group_id FROM group_members WHERE user_id = @viewer AND status = 'member'))`
+
+// SearchFilter narrows a community search. Zero values don't filter.
+type SearchFilter struct {
+	Query    string
+	ViewerID uint   // Group posts are only found by members
+	Type     string // "posts", "comments" or "" for both
+	AuthorID uint
+	From, To time.Time // Created at or after From and before To
+}
+
+// SearchCommunity ranks visible posts and comments matching the query in one list.
+// Title and Snippet mark matches with HighlightStart and HighlightStop.
+func (r *UserRepository) SearchCommunity(filter SearchFilter, limit, offset int) ([]models.SearchResult, error) {
+	params := map[string]interface{}{"query": filter.Query, "viewer": filter.ViewerID, "limit": limit, "offset": offset}
+	conditions := func(alias string) string {
+		var sql strings.Builder
+		if filter.AuthorID != 0 {
+			sql.WriteString(" AND " + alias + ".user_id = @author")
+			params["author"] = filter.AuthorID
+		}
+		if !filter.From.IsZero() {
+			sql.WriteString(" AND " + alias + ".created_at >= @from")
+			params["from"] = filter.From
+		}
+		if !filter.To.IsZero() {
+			sql.WriteString(" AND " + alias + ".created_at < @to")
+			params["to"] = filter.To
+		}
+		return sql.String()
+	}
+
+	var branches []string
+	if filter.Type != "comments" {
+		branches = append(branches, `SELECT 'post' AS type, p.id, p.id AS post_id, p.user_id, p.title, p.content,
+				ts_rank(p.search_vector, q) AS rank, p.created_at
+			FROM posts p, websearch_to_tsquery('`+searchConfig+`', @query) q
+			WHERE p.search_vector @@ q AND p.hidden_at IS NULL AND `+visibleGroupSQL+conditions("p"))
+	}
+	if filter.Type != "posts" {
+		branches = append(branches, `SELECT 'comment' AS type, c.id, c.post_id, c.user_id, '' AS title, c.content,
+				ts_rank(c.search_vector, q) AS rank, c.created_at
+			FROM comments c JOIN posts p ON p.id = c.post_id, websearch_to_tsquery('`+searchConfig+`', @query) q
+			WHERE c.search_vector @@ q AND c.deleted_at IS NULL AND c.hidden_at IS NULL AND p.hidden_at IS NULL AND `+visibleGroupSQL+conditions("c"))
+	}
+
+	// Headlines are expensive, so they are only built for the page that is returned
+	sql := `SELECT r.type, r.id, r.post_id, r.user_id, u.full_name AS author_name, u.avatar_url AS author_avatar,
+			CASE WHEN r.title = '' THEN '' ELSE ts_headline('` + searchConfig + `', r.title, q, 'HighlightAll=true, StartSel="` + HighlightStart + `", StopSel="` + HighlightStop + `"') END AS title,
+			ts_headline('` + searchConfig + `', r.content, q, 'MaxFragments=2, MaxWords=30, MinWords=10, StartSel="` + HighlightStart + `", StopSel="` + HighlightStop + `"') AS snippet,
+			r.rank, r.created_at
+		FROM (` + strings.Join(branches, " UNION ALL ") + ` ORDER BY rank DESC, created_at DESC LIMIT @limit OFFSET @offset) r
+		JOIN users u ON u.id = r.user_id, websearch_to_tsquery('` + searchConfig + `', @query) q
+		ORDER BY r.rank DESC, r.created_at DESC`
+
+	results := []models.SearchResult{}
+	err := r.Db.Raw(sql, params).Scan(&results).Error
+	return results, err
+}
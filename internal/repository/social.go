@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// SaveTags replaces the tags of a post or comment
+func (r *UserRepository) SaveTags(targetType string, targetID, postID, userID uint, names []string) error {
+	return r.Db.Transaction(func(tx *gorm.DB) error {
+		if err := deleteTagUsesTx(tx, targetType, targetID); err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return nil
+		}
+
+		tags := make([]models.Tag, len(names))
+		for i, name := range names {
+			tags[i] = models.Tag{Name: name}
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+			return err
+		}
+		// Tags that already existed got no ID from the insert
+		var tagIDs []uint
+		if err := tx.Model(&models.Tag{}).Where("name IN ?", names).Pluck("id", &tagIDs).Error; err != nil {
+			return err
+		}
+
+		uses := make([]models.TagUse, len(tagIDs))
+		for i, tagID := range tagIDs {
+			uses[i] = models.TagUse{TagID: tagID, TargetType: targetType, TargetID: targetID, PostID: postID, UserID: userID}
+		}
+		return tx.Create(&uses).Error
+	})
+}
+
+func (r *UserRepository) DeleteTagUses(targetType string, targetID uint) error {
+	return deleteTagUsesTx(r.Db, targetType, targetID)
+}
+
+func deleteTagUsesTx(tx *gorm.DB, targetType string, targetID uint) error {
+	return tx.Where("target_type = ? AND target_id = ?", targetType, targetID).Delete(&models.TagUse{}).Error
+}
+
+// GetTaggedPosts lists the posts the viewer can see that use the tag in the post or one of its comments, newest first
+func (r *UserRepository) GetTaggedPosts(tag string, viewerID uint, limit, offset int) ([]models.FeedPost, error) {
+	var posts []models.FeedPost
+	err := r.Db.Model(&models.Post{}).
+		Select("posts.*, users.full_name AS author_name, users.avatar_url AS author_avatar").
+		Joins("JOIN users ON users.id = posts.user_id").
+		Where("posts.group_id IS NULL OR posts.group_id IN (?)", r.memberGroups(viewerID)).
+		Where("posts.hidden_at IS NULL AND posts.id IN (?)",
+			r.Db.Model(&models.TagUse{}).Select("tag_uses.post_id").
+				Joins("JOIN tags ON tags.id = tag_uses.tag_id").
+				Where("tags.name = ?", tag)).
+		Order("posts.created_at DESC, posts.id DESC").
+		Limit(limit).Offset(offset).
+		Find(&posts).Error
+	return posts, err
+}
+
+// GetTrendingTags ranks the tags used since the given time by how many different people used them
+func (r *UserRepository) GetTrendingTags(since time.Time, limit int) ([]models.TrendingTag, error) {
+	tags := []models.TrendingTag{}
+	err := r.Db.Model(&models.TagUse{}).
+		Select("tags.name, COUNT(*) AS uses, COUNT(DISTINCT tag_uses.user_id) AS users").
+		Joins("JOIN tags ON tags.id = tag_uses.tag_id").
+		Where("tag_uses.created_at >= ?", since).
+		Group("tags.name").
+		Order("users DESC, uses DESC, tags.name").
+		Limit(limit).
+		Scan(&tags).Error
+	return tags, err
+}
+
+func (r *UserRepository) FindByUsernames(usernames []string) ([]models.User, error) {
+	var users []models.User
+	if len(usernames) == 0 {
+		return users, nil
+	}
+	err := r.Db.Where("username IN ?", usernames).Find(&users).Error
+	return users, err
+}
+
+// AddMentions stores the mentions and notifies each user the first time they are mentioned in the target.
+// It returns how many users were notified.
+func (r *UserRepository) AddMentions(mentions []models.Mention, text string) (int, error) {
+	notified := 0
+	err := r.Db.Transaction(func(tx *gorm.DB) error {
+		for i := range mentions {
+			mention := &mentions[i]
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(mention)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue // Already mentioned before an edit
+			}
+
+			notification := models.Notification{
+				UserID:  mention.MentionedUserID,
+				ActorID: mention.AuthorID,
+				Type:    "mention",
+				PostID:  &mention.PostID,
+				Text:    text,
+			}
+			if mention.TargetType == "comment" {
+				notification.CommentID = &mention.TargetID
+			}
+			if err := tx.Create(&notification).Error; err != nil {
+				return err
+			}
+			notified++
+		}
+		return nil
+	})
+	return notified, err
+}
+
+func (r *UserRepository) CreateNotification(notification *models.Notification) error {
+	return r.Db.Create(notification).Error
+}
+
+func (r *UserRepository) GetNotifications(userID uint, limit, offset int) ([]models.Notification, error) {
+	notifications := []models.Notification{}
+	err := r.Db.Where("user_id = ?", userID).Order("created_at desc, id desc").Limit(limit).Offset(offset).Find(&notifications).Error
+	return notifications, err
+}
+
+func (r *UserRepository) CountUnreadNotifications(userID uint) (int64, error) {
+	var count int64
+	err := r.Db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// MarkNotificationsRead marks the given notifications, or all of them when none are given, as read
+func (r *UserRepository) MarkNotificationsRead(userID uint, ids ...uint) error {
+	q := r.Db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if len(ids) > 0 {
+		q = q.Where("id IN ?", ids)
+	}
+	return q.Update("read_at", time.Now()).Error
+}
+
+func (r *UserRepository) Block(blockerID, blockedID uint) error {
+	return r.Db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.Block{BlockerID: blockerID, BlockedID: blockedID}).Error
+}
+
+func (r *UserRepository) Unblock(blockerID, blockedID uint) error {
+	return r.Db.Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).Delete(&models.Block{}).Error
+}
+
+// BlockersOf returns which of the given users have blocked userID
+func (r *UserRepository) BlockersOf(userID uint, candidates []uint) (map[uint]bool, error) {
+	blockers := make(map[uint]bool)
+	if len(candidates) == 0 {
+		return blockers, nil
+	}
+	var ids []uint
+	err := r.Db.Model(&models.Block{}).Where("blocked_id = ? AND blocker_id IN ?", userID, candidates).Pluck("blocker_id", &ids).Error
+	for _, id := range ids {
+		blockers[id] = true
+	}
+	return blockers, err
+}
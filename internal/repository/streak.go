@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"diplomIshi/internal/models"
+	"time"
+)
+
+func (r *UserRepository) GetStreaks(userID uint) ([]models.Streak, error) {
+	var streaks []models.Streak
+	err := r.Db.Where("user_id = ?", userID).Find(&streaks).Error
+	return streaks, err
+}
+
+// FindStreak returns the stored streak, or an empty one if the user has none yet
+func (r *UserRepository) FindStreak(userID uint, streakType string) (*models.Streak, error) {
+	var streaks []models.Streak
+	err := r.Db.Where("user_id = ? AND type = ?", userID, streakType).Limit(1).Find(&streaks).Error
+	if err != nil || len(streaks) == 0 {
+		return &models.Streak{UserID: userID, Type: streakType}, err
+	}
+	return &streaks[0], nil
+}
+
+func (r *UserRepository) GetStreakDays(userID uint, streakType string, from, to time.Time) ([]models.StreakDay, error) {
+	var days []models.StreakDay
+	err := r.Db.Where("user_id = ? AND type = ? AND date BETWEEN ? AND ?", userID, streakType, from, to).Order("date asc").Find(&days).Error
+	return days, err
+}
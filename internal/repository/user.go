@@ -3,6 +3,7 @@ package repository
 import (
 	"diplomIshi/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"time"
 )
 
@@ -24,12 +25,27 @@ func (r *UserRepository) FindByID(id uint) (*models.User, error) {
 	return &user, err
 }
 
-func (r *UserRepository) Update(user *models.User) error {
-	return r.Db.Save(user).Error
+// MissingUserIDs returns the given IDs that don't belong to any user
+func (r *UserRepository) MissingUserIDs(ids []uint) ([]uint, error) {
+	var found []uint
+	if err := r.Db.Model(&models.User{}).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+		return nil, err
+	}
+	exists := make(map[uint]bool, len(found))
+	for _, id := range found {
+		exists[id] = true
+	}
+	missing := []uint{}
+	for _, id := range ids {
+		if !exists[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
 }
 
-func (r *UserRepository) AddPoint(point *models.Point) error {
-	return r.Db.Create(point).Error
+func (r *UserRepository) Update(user *models.User) error {
+	return r.Db.Save(user).Error
 }
 
 func (r *UserRepository) AddAchievement(achievement *models.Achievement) error {
@@ -48,26 +64,13 @@ func (r *UserRepository) GetAchievements(userID uint) ([]models.Achievement, err
 	return achievements, err
 }
 
-func (r *UserRepository) GetRecentExerciseDays(userID uint) ([]time.Time, error) {
-	var progress []models.Progress
-	err := r.Db.Where("user_id = ?", userID).Order("date desc").Limit(7).Find(&progress).Error
-	if err != nil {
-		return nil, err
-	}
-	var days []time.Time
-	for _, p := range progress {
-		days = append(days, p.Date)
-	}
-	return days, nil
-}
-
 func (r *UserRepository) CreateReminder(reminder *models.Reminder) error {
 	return r.Db.Create(reminder).Error
 }
 
 func (r *UserRepository) GetReminders(userID uint) ([]models.Reminder, error) {
 	var reminders []models.Reminder
-	err := r.Db.Where("user_id = ? AND active = ?", userID, true).Find(&reminders).Error
+	err := r.withTimezone().Where("reminders.user_id = ? AND reminders.active = ?", userID, true).Find(&reminders).Error
 	return reminders, err
 }
 
@@ -75,19 +78,131 @@ func (r *UserRepository) UpdateReminder(reminder *models.Reminder) error {
 	return r.Db.Save(reminder).Error
 }
 
+func (r *UserRepository) GetActiveReminders() ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := r.withTimezone().Where("reminders.active = ?", true).Find(&reminders).Error
+	return reminders, err
+}
+
+// withTimezone selects reminders together with their user's time zone
+func (r *UserRepository) withTimezone() *gorm.DB {
+	return r.Db.Model(&models.Reminder{}).Select("reminders.*, users.timezone").
+		Joins("JOIN users ON users.id = reminders.user_id")
+}
+
+func (r *UserRepository) FindReminder(reminderID uint) (*models.Reminder, error) {
+	var reminder models.Reminder
+	err := r.Db.First(&reminder, reminderID).Error
+	return &reminder, err
+}
+
+// ClaimReminderFire inserts the log row for a reminder occurrence.
+// It returns false if another instance has already claimed the same occurrence.
+func (r *UserRepository) ClaimReminderFire(log *models.ReminderLog) (bool, error) {
+	result := r.Db.Clauses(clause.OnConflict{DoNothing: true}).Create(log)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *UserRepository) UpdateReminderLog(log *models.ReminderLog) error {
+	return r.Db.Save(log).Error
+}
+
+func (r *UserRepository) GetReminderLogs(reminderID, userID uint) ([]models.ReminderLog, error) {
+	var logs []models.ReminderLog
+	err := r.Db.Where("reminder_id = ? AND user_id = ?", reminderID, userID).Order("fired_at desc").Limit(50).Find(&logs).Error
+	return logs, err
+}
+
+func (r *UserRepository) GetPointRules() ([]models.PointRule, error) {
+	var rules []models.PointRule
+	err := r.Db.Order("action asc").Find(&rules).Error
+	return rules, err
+}
+
+func (r *UserRepository) FindPointRule(action string) (*models.PointRule, error) {
+	var rule models.PointRule
+	err := r.Db.Where("action = ?", action).First(&rule).Error
+	return &rule, err
+}
+
+func (r *UserRepository) SavePointRule(rule *models.PointRule) error {
+	return r.Db.Save(rule).Error
+}
+
+// LastProgressDate returns the date of the latest progress entry, or a zero time if there is none.
+func (r *UserRepository) LastProgressDate(userID uint) (time.Time, error) {
+	var progress []models.Progress
+	err := r.Db.Where("user_id = ?", userID).Order("date desc").Limit(1).Find(&progress).Error
+	if err != nil || len(progress) == 0 {
+		return time.Time{}, err
+	}
+	return progress[0].Date, nil
+}
+
 func (r *UserRepository) CreatePost(post *models.Post) error {
 	return r.Db.Create(post).Error
 }
 
-func (r *UserRepository) GetPosts() ([]models.Post, error) {
-	var posts []models.Post
-	err := r.Db.Order("created_at desc").Find(&posts).Error
-	return posts, err
+// GetFeedPage returns up to limit posts sorted by "new", "top" (most reactions) or "hot"
+// (reactions decaying with age), starting after the cursor. Posts carry their author's name and avatar.
+// Posts hidden by moderators are left out. A nil groupID lists the public posts outside of groups.
+func (r *UserRepository) GetFeedPage(cursor models.PostCursor, groupID *uint, limit int) ([]models.FeedPost, error) {
+	posts := r.Db.Model(&models.Post{}).
+		Joins("JOIN users ON users.id = posts.user_id").
+		Where("posts.hidden_at IS NULL")
+	if groupID != nil {
+		posts = posts.Where("posts.group_id = ?", *groupID)
+	} else {
+		posts = posts.Where("posts.group_id IS NULL")
+	}
+	switch cursor.Sort {
+	case "top":
+		posts = posts.Select("posts.*, users.full_name AS author_name, users.avatar_url AS author_avatar, COALESCE(rc.reaction_count, 0)::float8 AS score")
+	case "hot":
+		posts = posts.Select("posts.*, users.full_name AS author_name, users.avatar_url AS author_avatar, "+
+			"(COALESCE(rc.reaction_count, 0) + 1) / POWER(GREATEST(EXTRACT(EPOCH FROM (?::timestamptz - posts.created_at)), 0) / 3600 + 2, 1.5) AS score", cursor.AsOf)
+	default:
+		posts = posts.Select("posts.*, users.full_name AS author_name, users.avatar_url AS author_avatar, 0::float8 AS score")
+	}
+	if cursor.Sort == "top" || cursor.Sort == "hot" {
+		posts = posts.Joins("LEFT JOIN (SELECT target_id, COUNT(*) AS reaction_count FROM reactions WHERE target_type = 'post' GROUP BY target_id) rc ON rc.target_id = posts.id")
+	}
+
+	q := r.Db.Table("(?) AS feed", posts)
+	if cursor.ID != 0 {
+		q = q.Where("(score, created_at, id) < (?, ?, ?)", cursor.Score, cursor.CreatedAt, cursor.ID)
+	}
+	var page []models.FeedPost
+	err := q.Order("score DESC, created_at DESC, id DESC").Limit(limit).Find(&page).Error
+	return page, err
+}
+
+// CountComments counts the comments that are not deleted, per post
+func (r *UserRepository) CountComments(postIDs []uint) (map[uint]int, error) {
+	counts := make(map[uint]int, len(postIDs))
+	if len(postIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		PostID uint
+		Count  int
+	}
+	err := r.Db.Model(&models.Comment{}).
+		Select("post_id, COUNT(*) AS count").
+		Where("post_id IN ? AND deleted_at IS NULL", postIDs).
+		Group("post_id").Scan(&rows).Error
+	for _, row := range rows {
+		counts[row.PostID] = row.Count
+	}
+	return counts, err
 }
 
 func (r *UserRepository) GetPostByID(postID uint) (*models.Post, error) {
 	var post models.Post
-	err := r.Db.First(&post, postID).Error
+	err := r.Db.Preload("Images").First(&post, postID).Error
 	return &post, err
 }
 
@@ -101,6 +216,21 @@ func (r *UserRepository) GetComments(postID uint) ([]models.Comment, error) {
 	return comments, err
 }
 
+// GetCommentsPage returns one page of the post's comments in chronological order, with the total count
+func (r *UserRepository) GetCommentsPage(postID uint, limit, offset int) ([]models.Comment, int64, error) {
+	var total int64
+	if err := r.Db.Model(&models.Comment{}).Where("post_id = ?", postID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var comments []models.Comment
+	err := r.Db.Where("post_id = ?", postID).Order("created_at asc, id asc").Limit(limit).Offset(offset).Find(&comments).Error
+	return comments, total, err
+}
+
+func (r *UserRepository) UpdateComment(comment *models.Comment) error {
+	return r.Db.Save(comment).Error
+}
+
 func (r *UserRepository) DeleteOldPlans(userID uint) error {
 	if err := r.Db.Where("user_id = ?", userID).Delete(&models.Meal{}).Error; err != nil {
 		return err
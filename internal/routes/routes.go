@@ -5,8 +5,10 @@ import (
 	"diplomIshi/internal/handlers"
 	"diplomIshi/internal/repository"
 	"diplomIshi/internal/services"
+	"diplomIshi/internal/storage"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"log"
 )
 
 func SetupRoutes(cfg *config.Config) *gin.Engine {
@@ -15,42 +17,167 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"}, // Allow your frontend origin
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Idempotency-Key"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * 60 * 60, // 12 hours
 	}))
+	if cfg.StorageDir != "" {
+		r.Static(cfg.StorageURL, cfg.StorageDir)
+	}
+	if private, ok := cfg.PrivateStorage.(*storage.LocalPrivateStorage); ok {
+		r.GET(cfg.PrivateStorageURL+"/*key", handlers.ServePrivateFile(private))
+	}
+
 	userRepo := repository.NewUserRepository(cfg.DB)
+	events := services.NewEventBus(cfg.DB, cfg.EventBus)
 	calcSvc := services.NewCalculatorService(cfg.DB)
-	reminderSvc := services.NewReminderService(userRepo)
-	userHandler := handlers.NewUserHandler(userRepo, calcSvc, reminderSvc)
-	communityHandler := handlers.NewCommunityHandler(userRepo)
+	reminderSvc := services.NewReminderService(userRepo, events)
+	levelSvc := services.NewLevelService(userRepo, events, cfg.XPBase, cfg.XPGrowth)
+	feedSvc := services.NewFeedService(userRepo, services.NewFeedStrategy(userRepo, cfg.FeedFanOut))
+	achievementSvc := services.NewAchievementService(userRepo, levelSvc, feedSvc, events)
+	streakSvc := services.NewStreakService(userRepo, calcSvc)
+	leaderboardSvc := services.NewLeaderboardService(userRepo)
+	userHandler := handlers.NewUserHandler(userRepo, calcSvc, reminderSvc, achievementSvc, streakSvc, levelSvc, leaderboardSvc, feedSvc, events)
+	moderationSvc := services.NewModerationService(userRepo, services.NewDefaultWordListFilter())
+	communitySvc := services.NewCommunityService(userRepo, events)
+	communityHandler := handlers.NewCommunityHandler(userRepo, achievementSvc, feedSvc, moderationSvc, communitySvc, cfg.Storage, cfg.CommentMaxDepth, cfg.MaxUploadBytes)
+	adminHandler := handlers.NewAdminHandler(userRepo)
+	moderationHandler := handlers.NewModerationHandler(userRepo, moderationSvc, cfg.Storage)
+	challengeSvc := services.NewChallengeService(userRepo, achievementSvc)
+	challengeHandler := handlers.NewChallengeHandler(userRepo, challengeSvc)
+	groupHandler := handlers.NewGroupHandler(userRepo, events)
+	photoSvc := services.NewPhotoService(userRepo, streakSvc, cfg.PrivateStorage, cfg.PhotoURLTTL)
+	progressHandler := handlers.NewProgressHandler(userRepo, services.NewProgressService(userRepo, streakSvc), photoSvc, cfg.MaxUploadBytes)
+	messageHandler := handlers.NewMessageHandler(userRepo, services.NewMessageService(userRepo, events))
+	eventHandler := handlers.NewEventHandler(userRepo, events)
+	exportSvc := services.NewExportService(userRepo, cfg.PrivateStorage, cfg.ExportTTL)
+	exportHandler := handlers.NewExportHandler(userRepo, exportSvc)
+
+	if err := calcSvc.SeedPointRules(); err != nil {
+		log.Println("Failed to seed point rules:", err)
+	}
+	if err := achievementSvc.SeedDefinitions(); err != nil {
+		log.Println("Failed to seed achievements:", err)
+	}
+	if err := userRepo.RebuildPointAggregates(); err != nil {
+		log.Println("Failed to build leaderboard aggregates:", err)
+	}
 
 	r.POST("/register", userHandler.Register)
 	r.POST("/login", userHandler.Login)
+	r.GET("/events", handlers.StreamAuthMiddleware(), eventHandler.Stream)
 
 	auth := r.Group("/").Use(handlers.AuthMiddleware())
+	notSuspended := handlers.RequireNotSuspended(userRepo)
 	{
 		auth.POST("/progress", userHandler.AddProgress)
-		auth.GET("/progress/:user_id", userHandler.GetProgress)
+		auth.GET("/progress/:user_id", progressHandler.GetProgress)
+		auth.GET("/progress/:user_id/series", progressHandler.GetSeries)
+		auth.POST("/progress/photos", progressHandler.UploadPhoto)
+		auth.DELETE("/progress/photos/:photo_id", progressHandler.DeletePhoto)
+		auth.GET("/progress/:user_id/photos", progressHandler.GetPhotos)
+		auth.GET("/progress/:user_id/photos/compare", progressHandler.ComparePhotos)
+		auth.POST("/coaches", progressHandler.AddCoach)
+		auth.GET("/coaches", progressHandler.GetCoaches)
+		auth.GET("/coaches/clients", progressHandler.GetClients)
+		auth.DELETE("/coaches/:coach_id", progressHandler.RemoveCoach)
+		auth.POST("/measurements", progressHandler.LogMeasurement)
+		auth.GET("/measurements", progressHandler.GetMeasurements)
+		auth.DELETE("/measurements/:measurement_id", progressHandler.DeleteMeasurement)
 		auth.GET("/plan/:user_id", userHandler.GetPlan)
 		auth.POST("/complete", userHandler.CompleteAction)
+		auth.DELETE("/complete/:completion_id", userHandler.UndoCompletion)
 		auth.GET("/gamification/:user_id", userHandler.GetGamificationData)
+		auth.GET("/achievements", userHandler.GetAchievements)
+		auth.GET("/streaks", userHandler.GetStreaks)
+		auth.GET("/streaks/:type/calendar", userHandler.GetStreakCalendar)
+		auth.POST("/streaks/:type/freeze", userHandler.BuyStreakFreeze)
+		auth.POST("/water", userHandler.LogWater)
+		auth.GET("/leaderboard", userHandler.GetLeaderboard)
+		auth.PUT("/leaderboard/opt-out", userHandler.SetLeaderboardOptOut)
+		auth.POST("/users/:user_id/follow", communityHandler.FollowUser)
+		auth.DELETE("/users/:user_id/follow", communityHandler.UnfollowUser)
+		auth.GET("/users/:user_id/followers", communityHandler.GetFollowers)
+		auth.GET("/users/:user_id/following", communityHandler.GetFollowing)
+		auth.POST("/users/:user_id/block", communityHandler.BlockUser)
+		auth.DELETE("/users/:user_id/block", communityHandler.UnblockUser)
+		auth.GET("/feed", communityHandler.GetFeed)
+		auth.GET("/inbox", userHandler.GetInbox)
+		auth.PUT("/inbox/read", userHandler.MarkInboxRead)
+		auth.POST("/messages", notSuspended, messageHandler.SendMessage)
+		auth.GET("/messages/unread", messageHandler.GetUnreadCount)
+		auth.GET("/messages/conversations", messageHandler.GetConversations)
+		auth.GET("/messages/conversations/:conversation_id", messageHandler.GetMessages)
+		auth.PUT("/messages/conversations/:conversation_id/read", messageHandler.MarkConversationRead)
+		auth.POST("/events/ticket", eventHandler.CreateTicket)
+		auth.POST("/challenges", challengeHandler.CreateChallenge)
+		auth.GET("/challenges", challengeHandler.GetChallenges)
+		auth.GET("/challenges/:challenge_id", challengeHandler.GetChallenge)
+		auth.POST("/challenges/:challenge_id/invite", challengeHandler.InviteToChallenge)
+		auth.POST("/challenges/:challenge_id/join", challengeHandler.JoinChallenge)
+		auth.GET("/challenges/:challenge_id/standings", challengeHandler.GetStandings)
+		auth.POST("/groups", groupHandler.CreateGroup)
+		auth.GET("/groups", groupHandler.GetGroups)
+		auth.GET("/groups/leaderboard", groupHandler.GetGroupRankings)
+		auth.GET("/groups/:group_id", groupHandler.GetGroup)
+		auth.PUT("/groups/:group_id", groupHandler.UpdateGroup)
+		auth.POST("/groups/:group_id/join", groupHandler.JoinGroup)
+		auth.POST("/groups/:group_id/leave", groupHandler.LeaveGroup)
+		auth.POST("/groups/:group_id/invite", groupHandler.InviteToGroup)
+		auth.GET("/groups/:group_id/members", groupHandler.GetGroupMembers)
+		auth.PUT("/groups/:group_id/members/:user_id", groupHandler.UpdateGroupMember)
+		auth.DELETE("/groups/:group_id/members/:user_id", groupHandler.RemoveGroupMember)
+		auth.GET("/groups/:group_id/posts", communityHandler.GetGroupPosts)
 		auth.POST("/reminders", userHandler.CreateReminder)
+		auth.POST("/reminders/preview", userHandler.PreviewReminder)
 		auth.GET("/reminders", userHandler.GetReminders)
+		auth.GET("/reminders/:reminder_id/logs", userHandler.GetReminderLogs)
 		auth.PUT("/reminders/:reminder_id/disable", userHandler.DisableReminder)
-		auth.POST("/community/posts", communityHandler.CreatePost)
+		auth.POST("/community/posts", notSuspended, communityHandler.CreatePost)
 		auth.GET("/community/posts", communityHandler.GetPosts)
-		auth.POST("/community/posts/:post_id/comments", communityHandler.CreateComment)
+		auth.GET("/community/search", communityHandler.Search)
+		auth.GET("/community/tags", communityHandler.GetTrendingTags)
+		auth.GET("/community/tags/:tag", communityHandler.GetTaggedPosts)
+		auth.PUT("/community/posts/:post_id", notSuspended, communityHandler.UpdatePost)
+		auth.DELETE("/community/posts/:post_id", communityHandler.DeletePost)
+		auth.POST("/community/posts/:post_id/images", notSuspended, communityHandler.UploadPostImage)
+		auth.DELETE("/community/posts/:post_id/images/:image_id", communityHandler.DeletePostImage)
+		auth.POST("/community/posts/:post_id/comments", notSuspended, communityHandler.CreateComment)
 		auth.GET("/community/posts/:post_id/comments", communityHandler.GetComments)
+		auth.POST("/community/posts/:post_id/reactions", notSuspended, communityHandler.ReactToPost)
+		auth.POST("/community/comments/:comment_id/reactions", notSuspended, communityHandler.ReactToComment)
+		auth.PUT("/community/comments/:comment_id", notSuspended, communityHandler.UpdateComment)
+		auth.DELETE("/community/comments/:comment_id", communityHandler.DeleteComment)
+		auth.POST("/community/posts/:post_id/report", moderationHandler.ReportPost)
+		auth.POST("/community/comments/:comment_id/report", moderationHandler.ReportComment)
+		auth.GET("/moderation/warnings", moderationHandler.GetMyWarnings)
 		auth.PUT("/update", userHandler.UpdateUser)
+		auth.PUT("/username", userHandler.SetUsername)
 		auth.GET("/users", userHandler.GetAllUsers)
+		auth.GET("/export", exportHandler.Export)
+	}
+
+	admin := r.Group("/admin").Use(handlers.AuthMiddleware(), handlers.RequireRole(userRepo, "admin"))
+	{
+		admin.GET("/point-rules", adminHandler.GetPointRules)
+		admin.PUT("/point-rules/:action", adminHandler.SavePointRule)
+		admin.PUT("/users/:user_id/role", moderationHandler.SetRole)
+		admin.GET("/users/:user_id/export", exportHandler.ExportUser)
+	}
+
+	moderation := r.Group("/moderation").Use(handlers.AuthMiddleware(), handlers.RequireRole(userRepo, "moderator", "admin"))
+	{
+		moderation.GET("/queue", moderationHandler.GetQueue)
+		moderation.POST("/actions", moderationHandler.Moderate)
+		moderation.GET("/audit", moderationHandler.GetAuditLog)
 	}
 
 	reminderSvc.Start()
-	users := []uint{1} // Replace with dynamic user list if needed
-	for _, userID := range users {
-		reminderSvc.LoadReminders(userID)
+	challengeSvc.Start()
+	exportSvc.Start()
+	if err := reminderSvc.LoadAllReminders(); err != nil {
+		log.Println("Failed to load reminders:", err)
 	}
 
 	return r
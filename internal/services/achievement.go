@@ -0,0 +1,208 @@
+package services
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"fmt"
+	"log"
+	"time"
+)
+
+var defaultAchievements = []models.AchievementDefinition{
+	{Key: "first_workout", Title: "First Workout", Description: "Complete your first workout", Icon: "dumbbell", Criteria: "total_workouts", Threshold: 1},
+	{Key: "workouts_10", Title: "10 Workouts", Description: "Complete 10 workouts", Icon: "medal", Criteria: "total_workouts", Threshold: 10},
+	{Key: "workouts_50", Title: "50 Workouts", Description: "Complete 50 workouts", Icon: "trophy", Criteria: "total_workouts", Threshold: 50},
+	{Key: "workout_streak_5", Title: "5-Day Workout Streak", Description: "Work out 5 days in a row", Icon: "fire", Criteria: "workout_streak", Threshold: 5},
+	{Key: "workout_streak_30", Title: "30-Day Workout Streak", Description: "Work out 30 days in a row", Icon: "flame", Criteria: "workout_streak", Threshold: 30},
+	{Key: "weight_lost_5", Title: "5 kg Down", Description: "Lose 5 kg since your first weigh-in", Icon: "scale", Criteria: "weight_lost", Threshold: 5},
+	{Key: "weight_lost_10", Title: "10 kg Down", Description: "Lose 10 kg since your first weigh-in", Icon: "star", Criteria: "weight_lost", Threshold: 10},
+	{Key: "first_post", Title: "Hello Community", Description: "Write your first post", Icon: "chat", Criteria: "posts_made", Threshold: 1},
+	{Key: "posts_10", Title: "Storyteller", Description: "Write 10 posts", Icon: "book", Criteria: "posts_made", Threshold: 10},
+	{Key: "points_1000", Title: "1000 Points", Description: "Earn 1000 points", Icon: "coin", Criteria: "total_points", Threshold: 1000},
+	{Key: "level_5", Title: "Level 5", Description: "Reach level 5", Icon: "badge", Criteria: "level", Threshold: 5},
+	{Key: "level_10", Title: "Level 10", Description: "Reach level 10", Icon: "crown", Criteria: "level", Threshold: 10},
+}
+
+type AchievementService struct {
+	repo     *repository.UserRepository
+	levelSvc *LevelService
+	feedSvc  *FeedService
+	events   EventBus
+}
+
+func NewAchievementService(repo *repository.UserRepository, levelSvc *LevelService, feedSvc *FeedService, events EventBus) *AchievementService {
+	return &AchievementService{repo: repo, levelSvc: levelSvc, feedSvc: feedSvc, events: events}
+}
+
+// AchievementProgress shows how close the user is to an achievement
+type AchievementProgress struct {
+	models.AchievementDefinition
+	Current    float64    `json:"current"`
+	Percent    float64    `json:"percent"`
+	Unlocked   bool       `json:"unlocked"`
+	UnlockedAt *time.Time `json:"unlocked_at,omitempty"`
+}
+
+// SeedDefinitions creates the default catalog entries that don't exist yet
+func (s *AchievementService) SeedDefinitions() error {
+	for _, definition := range defaultAchievements {
+		if err := s.repo.SeedAchievementDefinition(&definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Evaluate unlocks every achievement whose criteria the user now meets.
+// It is called after events that can change a metric (workouts, progress, posts).
+func (s *AchievementService) Evaluate(userID uint) ([]models.Achievement, error) {
+	definitions, err := s.repo.GetAchievementDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	unlocked, err := s.unlockedByKey(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make(map[string]float64)
+	var newlyUnlocked []models.Achievement
+	for _, def := range definitions {
+		if _, ok := unlocked[def.Key]; ok {
+			continue
+		}
+		value, err := s.metric(userID, def.Criteria, metrics)
+		if err != nil {
+			return nil, err
+		}
+		if value < def.Threshold {
+			continue
+		}
+
+		achievement := models.Achievement{UserID: userID, Key: def.Key, Name: def.Title}
+		created, err := s.Unlock(&achievement)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			newlyUnlocked = append(newlyUnlocked, achievement)
+		}
+	}
+	return newlyUnlocked, nil
+}
+
+// Unlock grants the achievement unless the user already has it, tells the user and shares it with followers.
+// It reports whether the achievement is new.
+func (s *AchievementService) Unlock(achievement *models.Achievement) (bool, error) {
+	created, err := s.repo.UnlockAchievement(achievement)
+	if err != nil || !created {
+		return false, err
+	}
+	s.events.Publish(achievement.UserID, Event{Type: "achievement", Data: achievement})
+	s.feedSvc.PublishAchievement(achievement)
+	return true, nil
+}
+
+// EvaluateAsync runs Evaluate for events where the caller doesn't need the result
+func (s *AchievementService) EvaluateAsync(userID uint) {
+	go func() {
+		if _, err := s.Evaluate(userID); err != nil {
+			log.Printf("Failed to evaluate achievements for user %d: %v", userID, err)
+		}
+	}()
+}
+
+// Progress lists every catalog entry with the user's current value
+func (s *AchievementService) Progress(userID uint) ([]AchievementProgress, error) {
+	definitions, err := s.repo.GetAchievementDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	unlocked, err := s.unlockedByKey(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make(map[string]float64)
+	progress := make([]AchievementProgress, 0, len(definitions))
+	for _, def := range definitions {
+		value, err := s.metric(userID, def.Criteria, metrics)
+		if err != nil {
+			return nil, err
+		}
+		item := AchievementProgress{AchievementDefinition: def, Current: value}
+		if ach, ok := unlocked[def.Key]; ok {
+			item.Unlocked = true
+			item.UnlockedAt = &ach.CreatedAt
+			item.Percent = 100
+		} else if def.Threshold > 0 {
+			item.Percent = min(value/def.Threshold*100, 100)
+		}
+		progress = append(progress, item)
+	}
+	return progress, nil
+}
+
+func (s *AchievementService) unlockedByKey(userID uint) (map[string]models.Achievement, error) {
+	achievements, err := s.repo.GetAchievements(userID)
+	if err != nil {
+		return nil, err
+	}
+	unlocked := make(map[string]models.Achievement, len(achievements))
+	for _, ach := range achievements {
+		unlocked[ach.Key] = ach
+	}
+	return unlocked, nil
+}
+
+// metric computes the user's value for a criteria type, caching it for the current evaluation
+func (s *AchievementService) metric(userID uint, criteria string, cache map[string]float64) (float64, error) {
+	if value, ok := cache[criteria]; ok {
+		return value, nil
+	}
+
+	var value float64
+	switch criteria {
+	case "total_workouts":
+		count, err := s.repo.CountCompletions(userID, "exercise")
+		if err != nil {
+			return 0, err
+		}
+		value = float64(count)
+	case "workout_streak":
+		streak, err := s.repo.FindStreak(userID, "workout")
+		if err != nil {
+			return 0, err
+		}
+		value = float64(streak.Longest) // Reached once, kept even if the streak breaks later
+	case "weight_lost":
+		lost, err := s.repo.WeightLost(userID)
+		if err != nil {
+			return 0, err
+		}
+		value = max(lost, 0)
+	case "posts_made":
+		count, err := s.repo.CountPosts(userID)
+		if err != nil {
+			return 0, err
+		}
+		value = float64(count)
+	case "total_points":
+		total, err := s.repo.GetXP(userID)
+		if err != nil {
+			return 0, err
+		}
+		value = float64(total)
+	case "level":
+		info, err := s.levelSvc.UserLevel(userID)
+		if err != nil {
+			return 0, err
+		}
+		value = float64(info.Level)
+	default:
+		return 0, fmt.Errorf("unknown achievement criteria %q", criteria)
+	}
+
+	cache[criteria] = value
+	return value, nil
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"diplomIshi/internal/models"
+	"errors"
 	"gorm.io/gorm"
 	"math"
 	"time"
@@ -94,46 +95,125 @@ func (s *CalculatorService) GenerateExercisePlan(user *models.User) []models.Exe
 	return userExercises
 }
 
-func (s *CalculatorService) AwardPoints(userID uint, action string) models.Point {
-	points := 0
-	reason := ""
-	switch action {
-	case "meal":
-		points = 10
-		reason = "Completed meal"
-	case "exercise":
-		points = 20
-		reason = "Completed exercise"
+var (
+	ErrUnknownAction     = errors.New("unknown action")
+	ErrUnknownDifficulty = errors.New("unknown difficulty")
+)
+
+// Default rules keep the original 10/20 points for meals and exercises
+var defaultPointRules = []models.PointRule{
+	{Action: "meal", Reason: "Completed meal", BasePoints: 10, Active: true},
+	{Action: "exercise", Reason: "Completed exercise", BasePoints: 20, Active: true},
+}
+
+// SeedPointRules creates the default rules that don't exist yet
+func (s *CalculatorService) SeedPointRules() error {
+	for _, rule := range defaultPointRules {
+		if err := s.db.Where("action = ?", rule.Action).FirstOrCreate(&rule).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AwardPoints applies the point rule of the action: base points, difficulty multiplier and streak bonus.
+// The rule's daily cap is returned rather than applied, it is nil when the action is unlimited.
+func (s *CalculatorService) AwardPoints(userID uint, action, difficulty string) (models.Point, *models.PointCap, error) {
+	var rule models.PointRule
+	err := s.db.Where("action = ? AND active = ?", action, true).First(&rule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Point{}, nil, ErrUnknownAction
+	}
+	if err != nil {
+		return models.Point{}, nil, err
+	}
+
+	multiplier := 1.0
+	if difficulty != "" {
+		m, ok := rule.DifficultyMultipliers[difficulty]
+		if !ok {
+			return models.Point{}, nil, ErrUnknownDifficulty
+		}
+		multiplier = m
+	}
+
+	// The day, for both the cap and the streak, starts at midnight in the user's time zone
+	var user models.User
+	if err := s.db.Select("timezone").First(&user, userID).Error; err != nil {
+		return models.Point{}, nil, err
+	}
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		loc = time.UTC
 	}
+	now := time.Now().In(loc)
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	if rule.StreakBonus > 0 {
+		streak, err := s.actionStreak(userID, action, startOfDay)
+		if err != nil {
+			return models.Point{}, nil, err
+		}
+		bonus := float64(streak) * rule.StreakBonus
+		if rule.MaxStreakBonus > 0 && bonus > rule.MaxStreakBonus {
+			bonus = rule.MaxStreakBonus
+		}
+		multiplier *= 1 + bonus
+	}
+
+	var pointCap *models.PointCap
+	if rule.DailyCap > 0 {
+		pointCap = &models.PointCap{Limit: rule.DailyCap, Since: startOfDay}
+	}
+
 	return models.Point{
 		UserID: userID,
-		Points: points,
-		Reason: reason,
+		Action: action,
+		Points: int(math.Round(float64(rule.BasePoints) * multiplier)),
+		Reason: rule.Reason,
+	}, pointCap, nil
+}
+
+// actionStreak counts the consecutive days before today on which the action earned points
+func (s *CalculatorService) actionStreak(userID uint, action string, startOfDay time.Time) (int, error) {
+	var times []time.Time
+	err := s.db.Model(&models.Point{}).
+		Where("user_id = ? AND action = ? AND created_at >= ? AND created_at < ?", userID, action, startOfDay.AddDate(0, 0, -60), startOfDay).
+		Pluck("created_at", &times).Error
+	if err != nil {
+		return 0, err
 	}
+
+	days := make(map[string]bool)
+	for _, t := range times {
+		days[t.In(startOfDay.Location()).Format("2006-01-02")] = true
+	}
+
+	streak := 0
+	for day := startOfDay.AddDate(0, 0, -1); days[day.Format("2006-01-02")]; day = day.AddDate(0, 0, -1) {
+		streak++
+	}
+	return streak, nil
 }
 
-func (s *CalculatorService) CheckAchievements(userID uint, recentDays []time.Time) []models.Achievement {
-	var achievements []models.Achievement
-	now := time.Now()
-
-	// Check for 5-day workout streak
-	if len(recentDays) >= 5 {
-		streak := true
-		for i := 0; i < 4; i++ {
-			if recentDays[i].Sub(recentDays[i+1]).Hours() > 48 { // More than 2 days gap
-				streak = false
-				break
+// InPlan reports whether the meal or exercise is part of the plan generated for the user
+func (s *CalculatorService) InPlan(user *models.User, itemType string, itemID uint) bool {
+	_, meals, exercises := s.UpdateUserPlan(user)
+	switch itemType {
+	case "meal":
+		for _, meal := range meals {
+			if meal.ID == itemID {
+				return true
 			}
 		}
-		if streak && recentDays[0].Truncate(24*time.Hour).Equal(now.Truncate(24*time.Hour)) {
-			achievements = append(achievements, models.Achievement{
-				UserID: userID,
-				Name:   "5-Day Workout Streak",
-			})
+	case "exercise":
+		for _, ex := range exercises {
+			if ex.ID == itemID {
+				return true
+			}
 		}
 	}
-
-	return achievements
+	return false
 }
 
 func (s *CalculatorService) UpdateUserPlan(user *models.User) (float64, []models.Meal, []models.Exercise) {
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+var ErrInvalidChallenge = errors.New("invalid challenge")
+
+var challengeMetrics = map[string]bool{
+	"workouts":            true,
+	"points":              true,
+	"steps":               true,
+	"weight_loss_percent": true,
+}
+
+type ChallengeService struct {
+	repo           *repository.UserRepository
+	achievementSvc *AchievementService
+	stop           chan struct{}
+}
+
+func NewChallengeService(repo *repository.UserRepository, achievementSvc *AchievementService) *ChallengeService {
+	return &ChallengeService{repo: repo, achievementSvc: achievementSvc, stop: make(chan struct{})}
+}
+
+// Start closes finished challenges once a minute
+func (s *ChallengeService) Start() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.CloseExpired()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *ChallengeService) Stop() {
+	close(s.stop)
+}
+
+// Validate checks a new challenge and fills in its defaults
+func (s *ChallengeService) Validate(challenge *models.Challenge) error {
+	if !challengeMetrics[challenge.Metric] {
+		return fmt.Errorf("%w: unknown metric %q", ErrInvalidChallenge, challenge.Metric)
+	}
+	if challenge.Visibility == "" {
+		challenge.Visibility = "invite"
+	}
+	if challenge.Visibility != "open" && challenge.Visibility != "invite" {
+		return fmt.Errorf("%w: visibility must be open or invite", ErrInvalidChallenge)
+	}
+	if !challenge.EndsAt.After(challenge.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidChallenge)
+	}
+	if challenge.EndsAt.Before(time.Now()) {
+		return fmt.Errorf("%w: ends_at must be in the future", ErrInvalidChallenge)
+	}
+	challenge.Status = "active"
+	challenge.WinnerID = nil
+	return nil
+}
+
+// Standings scores every joined participant, best first
+func (s *ChallengeService) Standings(challenge *models.Challenge) ([]models.ChallengeStanding, error) {
+	standings, err := s.repo.GetJoinedStandings(challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	scores, err := s.scores(challenge)
+	if err != nil {
+		return nil, err
+	}
+	for i := range standings {
+		standings[i].Score = scores[standings[i].UserID]
+	}
+
+	// Stable sort keeps the earlier joiner ahead on ties
+	sort.SliceStable(standings, func(i, j int) bool {
+		return standings[i].Score > standings[j].Score
+	})
+	for i := range standings {
+		if i > 0 && standings[i].Score == standings[i-1].Score {
+			standings[i].Rank = standings[i-1].Rank
+		} else {
+			standings[i].Rank = i + 1
+		}
+	}
+	return standings, nil
+}
+
+// CloseExpired determines the winners of finished challenges and awards them an achievement
+func (s *ChallengeService) CloseExpired() {
+	challenges, err := s.repo.GetExpiredChallenges(time.Now())
+	if err != nil {
+		log.Println("Failed to fetch expired challenges:", err)
+		return
+	}
+	for i := range challenges {
+		if err := s.close(&challenges[i]); err != nil {
+			log.Printf("Failed to close challenge %d: %v", challenges[i].ID, err)
+		}
+	}
+}
+
+func (s *ChallengeService) close(challenge *models.Challenge) error {
+	standings, err := s.Standings(challenge)
+	if err != nil {
+		return err
+	}
+
+	// Tied leaders all win. The challenge's winner_id keeps the earliest joiner among them.
+	var winners []uint
+	for _, standing := range standings {
+		if standing.Rank > 1 || standing.Score <= 0 {
+			break
+		}
+		winners = append(winners, standing.UserID)
+	}
+	var winnerID *uint
+	if len(winners) > 0 {
+		winnerID = &winners[0]
+	}
+
+	// Only the instance that closes the challenge awards the winners
+	closed, err := s.repo.CloseChallenge(challenge.ID, winnerID)
+	if err != nil || !closed {
+		return err
+	}
+
+	for _, userID := range winners {
+		_, err := s.achievementSvc.Unlock(&models.Achievement{
+			UserID: userID,
+			Key:    fmt.Sprintf("challenge_winner_%d", challenge.ID),
+			Name:   "Won challenge: " + challenge.Title,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scores computes the joined participants' values of the challenge metric within the challenge window,
+// keyed by user ID. Participants without a value are missing from the map and score 0.
+func (s *ChallengeService) scores(challenge *models.Challenge) (map[uint]float64, error) {
+	from, to := challenge.StartsAt, challenge.EndsAt
+	switch challenge.Metric {
+	case "workouts":
+		return s.repo.CountCompletionsByParticipant(challenge.ID, "exercise", from, to)
+	case "points":
+		return s.repo.SumPointsByParticipant(challenge.ID, from, to)
+	case "steps":
+		return s.repo.SumStepsByParticipant(challenge.ID, from, to)
+	case "weight_loss_percent":
+		start, err := s.repo.WeightsAtByParticipant(challenge.ID, from)
+		if err != nil {
+			return nil, err
+		}
+		first, err := s.repo.FirstWeightsByParticipant(challenge.ID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		current, err := s.repo.WeightsAtByParticipant(challenge.ID, to)
+		if err != nil {
+			return nil, err
+		}
+		scores := make(map[uint]float64, len(current))
+		for userID, weight := range current {
+			initial := start[userID]
+			if initial == 0 {
+				// No weigh-in before the start, use the first one during the challenge
+				initial = first[userID]
+			}
+			if initial > 0 {
+				scores[userID] = (initial - weight) / initial * 100
+			}
+		}
+		return scores, nil
+	}
+	return nil, fmt.Errorf("unknown metric %q", challenge.Metric)
+}
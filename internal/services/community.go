@@ -0,0 +1,143 @@
+package services
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"log"
+	"regexp"
+	"strings"
+)
+
+var (
+	tagPattern     = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_#&])#([\p{L}\p{N}_]{1,50})`)
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.])@([A-Za-z0-9_]{3,30})`) // Not e-mail addresses
+
+	UsernamePattern = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+)
+
+// ParseTags returns the distinct #tags in the text, lowercase and without the #
+func ParseTags(text string) []string {
+	return uniqueMatches(tagPattern, text)
+}
+
+// ParseMentions returns the distinct @usernames in the text, lowercase and without the @
+func ParseMentions(text string) []string {
+	return uniqueMatches(mentionPattern, text)
+}
+
+func uniqueMatches(pattern *regexp.Regexp, text string) []string {
+	seen := make(map[string]bool)
+	var values []string
+	for _, match := range pattern.FindAllStringSubmatch(text, -1) {
+		value := strings.ToLower(match[1])
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// CommunityService keeps the tags and mentions of posts and comments in sync with their text
+// and tells authors about activity on their content
+type CommunityService struct {
+	repo   *repository.UserRepository
+	events EventBus
+}
+
+func NewCommunityService(repo *repository.UserRepository, events EventBus) *CommunityService {
+	return &CommunityService{repo: repo, events: events}
+}
+
+// NotifyComment pushes a new comment to the author of the post
+func (s *CommunityService) NotifyComment(post *models.Post, comment *models.Comment) {
+	if post.UserID != comment.UserID {
+		s.events.Publish(post.UserID, Event{Type: "comment", Data: comment})
+	}
+}
+
+// NotifyReaction pushes a new or changed reaction to the author of the post or comment
+func (s *CommunityService) NotifyReaction(authorID uint, reaction *models.Reaction) {
+	if authorID != reaction.UserID {
+		s.events.Publish(authorID, Event{Type: "reaction", Data: reaction})
+	}
+}
+
+func (s *CommunityService) IndexPost(post *models.Post) {
+	s.index("post", post.ID, post.ID, post.UserID, post.Title+"\n"+post.Content)
+}
+
+func (s *CommunityService) IndexComment(comment *models.Comment) {
+	s.index("comment", comment.ID, comment.PostID, comment.UserID, comment.Content)
+}
+
+// index stores the tags and notifies newly mentioned users. Failures are only logged,
+// since the post or comment itself is already saved.
+func (s *CommunityService) index(targetType string, targetID, postID, authorID uint, text string) {
+	if err := s.repo.SaveTags(targetType, targetID, postID, authorID, ParseTags(text)); err != nil {
+		log.Printf("Failed to save tags of %s %d: %v", targetType, targetID, err)
+	}
+	if err := s.notifyMentions(targetType, targetID, postID, authorID, text); err != nil {
+		log.Printf("Failed to save mentions of %s %d: %v", targetType, targetID, err)
+	}
+}
+
+// notifyMentions ignores unknown usernames, the author themselves, users who blocked the author
+// and, in group posts, users outside the group
+func (s *CommunityService) notifyMentions(targetType string, targetID, postID, authorID uint, text string) error {
+	users, err := s.repo.FindByUsernames(ParseMentions(text))
+	if err != nil || len(users) == 0 {
+		return err
+	}
+	ids := make([]uint, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	skip, err := s.repo.BlockersOf(authorID, ids)
+	if err != nil {
+		return err
+	}
+	post, err := s.repo.GetPostByID(postID)
+	if err != nil {
+		return err
+	}
+	if post.GroupID != nil {
+		members, err := s.repo.GroupMemberIDs(*post.GroupID)
+		if err != nil {
+			return err
+		}
+		inGroup := make(map[uint]bool, len(members))
+		for _, id := range members {
+			inGroup[id] = true
+		}
+		for _, id := range ids {
+			if !inGroup[id] {
+				skip[id] = true
+			}
+		}
+	}
+
+	var mentions []models.Mention
+	for _, user := range users {
+		if user.ID == authorID || skip[user.ID] {
+			continue
+		}
+		mentions = append(mentions, models.Mention{
+			TargetType:      targetType,
+			TargetID:        targetID,
+			MentionedUserID: user.ID,
+			PostID:          postID,
+			AuthorID:        authorID,
+		})
+	}
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	author, err := s.repo.FindByID(authorID)
+	if err != nil {
+		return err
+	}
+	_, err = s.repo.AddMentions(mentions, author.FullName+" mentioned you in a "+targetType)
+	return err
+}
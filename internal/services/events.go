@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+	"log"
+	"sync"
+	"time"
+)
+
+// Event is pushed to the connected clients of a user, e.g. "message", "comment", "reaction",
+// "reminder", "achievement" or "points". A "resync" event tells clients that events may have
+// been lost and they should reload what they show.
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// EventBus delivers events to the open streams of users
+type EventBus interface {
+	Publish(userID uint, event Event)
+	Subscribe(userID uint) (<-chan Event, func())
+}
+
+// NewEventBus picks the bus by name: "postgres" reaches the streams on every instance
+// and starts listening right away, anything else only reaches this instance
+func NewEventBus(db *gorm.DB, name string) EventBus {
+	if name == "postgres" {
+		bus := NewPostgresBus(db)
+		go bus.listen(context.Background())
+		return bus
+	}
+	return NewHub()
+}
+
+// Hub is the in-process bus. It keeps the open event streams of the users connected to this instance.
+type Hub struct {
+	mu      sync.RWMutex
+	streams map[uint]map[chan Event]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{streams: make(map[uint]map[chan Event]struct{})}
+}
+
+// Subscribe opens a stream for the user. The returned function closes it.
+func (h *Hub) Subscribe(userID uint) (<-chan Event, func()) {
+	stream := make(chan Event, 16)
+	h.mu.Lock()
+	if h.streams[userID] == nil {
+		h.streams[userID] = make(map[chan Event]struct{})
+	}
+	h.streams[userID][stream] = struct{}{}
+	h.mu.Unlock()
+
+	return stream, func() {
+		h.mu.Lock()
+		delete(h.streams[userID], stream)
+		if len(h.streams[userID]) == 0 {
+			delete(h.streams, userID)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// Publish sends the event to every open stream of the user.
+// A stream that is too slow to keep up misses the event rather than blocking the sender.
+func (h *Hub) Publish(userID uint, event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for stream := range h.streams[userID] {
+		select {
+		case stream <- event:
+		default:
+		}
+	}
+}
+
+// broadcast sends the event to every open stream on this instance
+func (h *Hub) broadcast(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, streams := range h.streams {
+		for stream := range streams {
+			select {
+			case stream <- event:
+			default:
+			}
+		}
+	}
+}
+
+const (
+	eventChannel     = "fitapp_events"
+	maxNotifyPayload = 7900 // Postgres rejects NOTIFY payloads of 8000 bytes or more
+)
+
+// busMessage is the NOTIFY payload
+type busMessage struct {
+	UserID uint  `json:"user_id"`
+	Event  Event `json:"event"`
+}
+
+// PostgresBus fans events out across instances with Postgres LISTEN/NOTIFY.
+// Every instance listens on the channel and hands the events to the streams it holds in its Hub.
+type PostgresBus struct {
+	*Hub
+	db *gorm.DB
+}
+
+func NewPostgresBus(db *gorm.DB) *PostgresBus {
+	return &PostgresBus{Hub: NewHub(), db: db}
+}
+
+// Publish notifies every instance. Events that can't go through Postgres still reach this instance.
+func (b *PostgresBus) Publish(userID uint, event Event) {
+	payload, err := json.Marshal(busMessage{UserID: userID, Event: event})
+	if err == nil && len(payload) > maxNotifyPayload {
+		log.Printf("Event %s for user %d is too large to notify, delivering locally", event.Type, userID)
+		b.Hub.Publish(userID, event)
+		return
+	}
+	if err == nil {
+		err = b.db.Exec("SELECT pg_notify(?, ?)", eventChannel, string(payload)).Error
+	}
+	if err != nil {
+		log.Printf("Failed to notify event %s for user %d: %v", event.Type, userID, err)
+		b.Hub.Publish(userID, event)
+	}
+}
+
+// listen keeps a connection listening on the channel until ctx is done, reconnecting after failures
+func (b *PostgresBus) listen(ctx context.Context) {
+	for {
+		if err := b.listenOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Event listener stopped: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (b *PostgresBus) listenOnce(ctx context.Context) error {
+	sqlDB, err := b.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var listenErr error
+	conn.Raw(func(driverConn interface{}) error {
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			listenErr = fmt.Errorf("LISTEN needs the pgx driver, got %T", driverConn)
+			return nil
+		}
+		pgxConn := stdConn.Conn()
+		if _, listenErr = pgxConn.Exec(ctx, "LISTEN "+eventChannel); listenErr != nil {
+			return driver.ErrBadConn
+		}
+		// Notifications sent while no connection was listening are gone
+		b.Hub.broadcast(Event{Type: "resync"})
+		for {
+			notification, err := pgxConn.WaitForNotification(ctx)
+			if err != nil {
+				listenErr = err
+				// A connection that is still listening must not go back to the pool
+				return driver.ErrBadConn
+			}
+			var message busMessage
+			if err := json.Unmarshal([]byte(notification.Payload), &message); err != nil {
+				log.Printf("Failed to decode event: %v", err)
+				continue
+			}
+			b.Hub.Publish(message.UserID, message.Event)
+		}
+	})
+	return listenErr
+}
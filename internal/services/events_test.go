@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// startTestBus starts a PostgresBus on its own pool and waits until it listens
+func startTestBus(t *testing.T, ctx context.Context) (*PostgresBus, <-chan Event) {
+	t.Helper()
+	bus := NewPostgresBus(openTestDB(t))
+	// Subscribed before listening, so the "resync" sent once LISTEN is active shows the bus is ready
+	events, unsubscribe := bus.Subscribe(42)
+	t.Cleanup(unsubscribe)
+	go bus.listen(ctx)
+	expectEvent(t, events, "resync", 10*time.Second)
+	return bus, events
+}
+
+func expectEvent(t *testing.T, events <-chan Event, eventType string, timeout time.Duration) Event {
+	t.Helper()
+	select {
+	case event := <-events:
+		if event.Type != eventType {
+			t.Fatalf("got event %q, want %q", event.Type, eventType)
+		}
+		return event
+	case <-time.After(timeout):
+		t.Fatalf("no %q event within %v", eventType, timeout)
+		return Event{}
+	}
+}
+
+func TestPostgresBusFansOutAcrossInstances(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	first, firstEvents := startTestBus(t, ctx)
+	second, secondEvents := startTestBus(t, ctx)
+	otherUser, unsubscribe := second.Subscribe(43)
+	defer unsubscribe()
+
+	first.Publish(42, Event{Type: "message", Data: "hello"})
+	for _, events := range []<-chan Event{firstEvents, secondEvents} {
+		if event := expectEvent(t, events, "message", 5*time.Second); event.Data != "hello" {
+			t.Errorf("got data %v, want hello", event.Data)
+		}
+	}
+	select {
+	case event := <-otherUser:
+		t.Errorf("another user got %q", event.Type)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestPostgresBusResyncsAfterReconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus, events := startTestBus(t, ctx)
+
+	// Drop the listening connection like a database restart would
+	err := bus.db.Exec("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE query = ? AND pid <> pg_backend_pid()",
+		"LISTEN "+eventChannel).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, "resync", 15*time.Second)
+}
@@ -0,0 +1,475 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"diplomIshi/internal/storage"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"time"
+)
+
+const (
+	// exportStaleAfter is how long a job may go without a heartbeat before another worker assumes it was interrupted
+	exportStaleAfter = 30 * time.Minute
+	exportHeartbeat  = exportStaleAfter / 3
+)
+
+// ExportService builds ZIP archives of a user's personal data as CSV and JSON files.
+// Jobs are queued in the database and built in the background, so large accounts don't hold up a request.
+type ExportService struct {
+	repo  *repository.UserRepository
+	store storage.PrivateStorage
+	ttl   time.Duration // How long a finished archive stays downloadable
+	wake  chan struct{}
+	stop  chan struct{}
+}
+
+func NewExportService(repo *repository.UserRepository, store storage.PrivateStorage, ttl time.Duration) *ExportService {
+	return &ExportService{repo: repo, store: store, ttl: ttl, wake: make(chan struct{}, 1), stop: make(chan struct{})}
+}
+
+// Start builds queued exports as they come in and deletes expired archives once a minute
+func (s *ExportService) Start() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			s.RunPending()
+			select {
+			case <-ticker.C:
+				s.DeleteExpired()
+			case <-s.wake:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *ExportService) Stop() {
+	close(s.stop)
+}
+
+// Request returns the user's export that is queued, running or still downloadable,
+// or queues a new one. created reports whether a new job was queued.
+func (s *ExportService) Request(userID, requestedBy uint) (job *models.ExportJob, created bool, err error) {
+	job, err = s.repo.CurrentExportJob(userID, time.Now())
+	if err != nil || job != nil {
+		return job, false, err
+	}
+	job = &models.ExportJob{UserID: userID, RequestedBy: requestedBy, Status: "pending"}
+	if err := s.repo.CreateExportJob(job); err != nil {
+		return nil, false, err
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return job, true, nil
+}
+
+// Sign fills in the download link of a finished export. It is valid until the archive expires.
+func (s *ExportService) Sign(ctx context.Context, job *models.ExportJob) error {
+	if job.Status != "ready" || job.ExpiresAt == nil {
+		return nil
+	}
+	var err error
+	job.DownloadURL, err = s.store.SignedURL(ctx, job.Key, time.Until(*job.ExpiresAt))
+	return err
+}
+
+// RunPending builds queued exports until none are left
+func (s *ExportService) RunPending() {
+	for {
+		now := time.Now()
+		job, err := s.repo.ClaimExportJob(now, now.Add(-exportStaleAfter))
+		if err != nil {
+			log.Println("Failed to claim export job:", err)
+			return
+		}
+		if job == nil {
+			return
+		}
+		s.run(job)
+	}
+}
+
+func (s *ExportService) run(job *models.ExportJob) {
+	ctx := context.Background()
+	stopHeartbeat := s.heartbeat(job.ID)
+	size, err := s.upload(ctx, job)
+	stopHeartbeat()
+
+	now := time.Now()
+	job.CompletedAt = &now
+	if err != nil {
+		log.Printf("Failed to export data of user %d: %v", job.UserID, err)
+		job.Status = "failed"
+		job.Key = ""
+		job.Error = "Failed to build the export"
+	} else {
+		expiresAt := now.Add(s.ttl)
+		job.Status = "ready"
+		job.Size = int(size)
+		job.ExpiresAt = &expiresAt
+	}
+	if err := s.repo.UpdateExportJob(job); err != nil {
+		log.Println("Failed to save export job:", err)
+	}
+}
+
+// upload builds the archive in a temporary file, so it never has to fit in memory, and uploads it
+func (s *ExportService) upload(ctx context.Context, job *models.ExportJob) (int64, error) {
+	f, err := os.CreateTemp("", "export-*.zip")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := s.Build(ctx, job.UserID, f); err != nil {
+		return 0, err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return 0, err
+	}
+	job.Key = fmt.Sprintf("exports/%d/%d_%s.zip", job.UserID, job.ID, hex.EncodeToString(b))
+	return size, s.store.PutReader(ctx, job.Key, f, size, "application/zip")
+}
+
+// heartbeat keeps refreshing the running job until the returned function is called,
+// so a long build is not mistaken for an interrupted one and claimed by another worker
+func (s *ExportService) heartbeat(jobID uint) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(exportHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if err := s.repo.TouchExportJob(jobID, now); err != nil {
+					log.Println("Failed to refresh export job:", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// DeleteExpired removes the archives of expired exports
+func (s *ExportService) DeleteExpired() {
+	jobs, err := s.repo.GetExpiredExportJobs(time.Now())
+	if err != nil {
+		log.Println("Failed to load expired exports:", err)
+		return
+	}
+	for i := range jobs {
+		job := &jobs[i]
+		if err := s.store.Delete(context.Background(), job.Key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Println("Failed to delete export:", err)
+			continue
+		}
+		job.Status = "expired"
+		job.Key = ""
+		if err := s.repo.UpdateExportJob(job); err != nil {
+			log.Println("Failed to save export job:", err)
+		}
+	}
+}
+
+// Build writes the user's profile, progress, measurements, progress photos, food and workout logs, points,
+// achievements, reminders, posts, comments, direct messages, group memberships and moderation warnings
+// as a ZIP to out. Every table is written both as CSV and as JSON.
+func (s *ExportService) Build(ctx context.Context, userID uint, out io.Writer) error {
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	user.Password = ""
+
+	zw := zip.NewWriter(out)
+	w := &exportWriter{zw: zw}
+
+	w.table("profile", user, []string{"id", "full_name", "username", "height", "weight", "age", "gender", "activity_level",
+		"eating_habits", "goal", "target_weight", "waist_circumference", "role", "timezone", "leaderboard_opt_out", "created_at"},
+		[][]string{{exportUint(user.ID), user.FullName, exportString(user.Username), exportFloat(user.Height), exportFloat(user.Weight),
+			strconv.Itoa(user.Age), user.Gender, user.ActivityLevel, user.EatingHabits, user.Goal, exportFloat(user.TargetWeight),
+			exportFloat(user.WaistCircumference), user.Role, user.Timezone, strconv.FormatBool(user.LeaderboardOptOut), exportTime(user.CreatedAt)}})
+
+	progress, err := s.repo.GetProgressRecords(userID)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(progress))
+	for _, p := range progress {
+		rows = append(rows, []string{exportUint(p.ID), exportTime(p.Date), exportFloat(p.Weight), strconv.Itoa(p.Calories),
+			strconv.Itoa(p.Steps), exportTime(p.CreatedAt)})
+	}
+	w.table("progress", progress, []string{"id", "date", "weight", "calories", "steps", "created_at"}, rows)
+
+	measurements, err := s.repo.GetMeasurements(userID, time.Time{}, time.Now().AddDate(0, 0, 2))
+	if err != nil {
+		return err
+	}
+	rows = make([][]string, 0, len(measurements))
+	for _, m := range measurements {
+		rows = append(rows, []string{exportUint(m.ID), exportDate(m.Date), exportFloatPtr(m.Waist), exportFloatPtr(m.Hips),
+			exportFloatPtr(m.Chest), exportFloatPtr(m.Neck), exportFloatPtr(m.Arms), exportFloatPtr(m.Thighs),
+			exportFloatPtr(m.BodyFat), exportFloatPtr(m.RestingHeartRate)})
+	}
+	w.table("measurements", measurements, []string{"id", "date", "waist", "hips", "chest", "neck", "arms", "thighs",
+		"body_fat", "resting_heart_rate"}, rows)
+
+	photos, err := s.repo.GetProgressPhotos(userID, "")
+	if err != nil {
+		return err
+	}
+	rows = make([][]string, 0, len(photos))
+	for _, p := range photos {
+		file := fmt.Sprintf("photos/%s_%s_%d%s", exportDate(p.Date), p.Pose, p.ID, path.Ext(p.OriginalKey))
+		if err := w.copy(ctx, s.store, p.OriginalKey, file); err != nil {
+			return err
+		}
+		rows = append(rows, []string{exportUint(p.ID), exportDate(p.Date), p.Pose, p.Note, file, exportTime(p.CreatedAt)})
+	}
+	w.table("photos", photos, []string{"id", "date", "pose", "note", "file", "created_at"}, rows)
+
+	meals, err := s.repo.GetLoggedItems(userID, "meal")
+	if err != nil {
+		return err
+	}
+	rows = make([][]string, 0, len(meals))
+	for _, m := range meals {
+		rows = append(rows, []string{exportUint(m.ID), exportDate(m.Date), m.Name, m.MealType, strconv.Itoa(m.Calories),
+			strconv.Itoa(m.Protein), strconv.Itoa(m.Carbs), strconv.Itoa(m.Fat), exportTime(m.CreatedAt)})
+	}
+	w.table("food_log", meals, []string{"id", "date", "name", "meal_type", "calories", "protein", "carbs", "fat", "logged_at"}, rows)
+
+	exercises, err := s.repo.GetLoggedItems(userID, "exercise")
+	if err != nil {
+		return err
+	}
+	rows = make([][]string, 0, len(exercises))
+	for _, e := range exercises {
+		rows = append(rows, []string{exportUint(e.ID), exportDate(e.Date), e.Name, strconv.Itoa(e.Duration),
+			strconv.Itoa(e.CaloriesBurned), exportTime(e.CreatedAt)})
+	}
+	w.table("workout_log", exercises, []string{"id", "date", "name", "duration", "calories_burned", "logged_at"}, rows)
+
+	points, err := s.repo.GetPoints(userID)
+	if err != nil {
+		return err
+	}
+	rows = make([][]string, 0, len(points))
+	for _, p := range points {
+		rows = append(rows, []string{exportUint(p.ID), p.Action, strconv.Itoa(p.Points), p.Reason, exportTime(p.CreatedAt)})
+	}
+	w.table("points", points, []string{"id", "action", "points", "reason", "created_at"}, rows)
+
+	achievements, err := s.repo.GetAchievements(userID)
+	if err != nil {
+		return err
+	}
+	rows = make([][]string, 0, len(achievements))
+	for _, a := range achievements {
+		rows = append(rows, []string{exportUint(a.ID), a.Key, a.Name, exportTime(a.CreatedAt)})
+	}
+	w.table("achievements", achievements, []string{"id", "key", "name", "unlocked_at"}, rows)
+
+	reminders, err := s.repo.GetAllReminders(userID)
+	if err != nil {
+		return err
+	}
+	rows = make([][]string, 0, len(reminders))
+	for _, r := range reminders {
+		rows = append(rows, []string{exportUint(r.ID), r.Type, r.Time, r.Message, strconv.FormatBool(r.Active), exportTime(r.CreatedAt)})
+	}
+	w.table("reminders", reminders, []string{"id", "type", "time", "message", "active", "created_at"}, rows)
+
+	posts, err := s.repo.GetUserPosts(userID)
+	if err != nil {
+		return err
+	}
+	rows = make([][]string, 0, len(posts))
+	for _, p := range posts {
+		rows = append(rows, []string{exportUint(p.ID), exportUintPtr(p.GroupID), p.Title, p.Content, exportTime(p.CreatedAt),
+			exportTime(p.UpdatedAt), exportTimePtr(p.HiddenAt)})
+	}
+	w.table("posts", posts, []string{"id", "group_id", "title", "content", "created_at", "updated_at", "hidden_at"}, rows)
+
+	comments, err := s.repo.GetUserComments(userID)
+	if err != nil {
+		return err
+	}
+	rows = make([][]string, 0, len(comments))
+	for _, c := range comments {
+		rows = append(rows, []string{exportUint(c.ID), exportUint(c.PostID), exportUintPtr(c.ParentID), c.Content,
+			exportTime(c.CreatedAt), exportTimePtr(c.EditedAt), exportTimePtr(c.DeletedAt), exportTimePtr(c.HiddenAt)})
+	}
+	w.table("comments", comments, []string{"id", "post_id", "parent_id", "content", "created_at", "edited_at", "deleted_at",
+		"hidden_at"}, rows)
+
+	messages, err := s.repo.GetUserMessages(userID)
+	if err != nil {
+		return err
+	}
+	rows = make([][]string, 0, len(messages))
+	for _, m := range messages {
+		rows = append(rows, []string{exportUint(m.ID), exportUint(m.ConversationID), exportUint(m.SenderID),
+			exportUint(m.RecipientID), m.Content, exportTime(m.CreatedAt), exportTimePtr(m.ReadAt)})
+	}
+	w.table("messages", messages, []string{"id", "conversation_id", "sender_id", "recipient_id", "content", "sent_at",
+		"read_at"}, rows)
+
+	memberships, err := s.repo.GetGroupMemberships(userID)
+	if err != nil {
+		return err
+	}
+	rows = make([][]string, 0, len(memberships))
+	for _, m := range memberships {
+		rows = append(rows, []string{exportUint(m.GroupID), m.GroupName, m.Role, m.Status, exportTime(m.CreatedAt),
+			exportTimePtr(m.JoinedAt)})
+	}
+	w.table("groups", memberships, []string{"group_id", "group_name", "role", "status", "created_at", "joined_at"}, rows)
+
+	warnings, err := s.repo.GetWarnings(userID)
+	if err != nil {
+		return err
+	}
+	rows = make([][]string, 0, len(warnings))
+	for _, a := range warnings {
+		rows = append(rows, []string{exportUint(a.ID), a.Action, a.TargetType, exportUint(a.TargetID), a.Reason,
+			exportTimePtr(a.Until), exportTime(a.CreatedAt)})
+	}
+	w.table("moderation_warnings", warnings, []string{"id", "action", "target_type", "target_id", "reason", "until",
+		"created_at"}, rows)
+
+	if w.err != nil {
+		return w.err
+	}
+	return zw.Close()
+}
+
+// exportWriter adds files to the archive and keeps the first error, so Build can check it once
+type exportWriter struct {
+	zw  *zip.Writer
+	err error
+}
+
+// table writes name.json with the records and name.csv with the header and rows
+func (w *exportWriter) table(name string, records interface{}, header []string, rows [][]string) {
+	if w.err != nil {
+		return
+	}
+	f, err := w.zw.Create(name + ".json")
+	if err != nil {
+		w.err = err
+		return
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(records); err != nil {
+		w.err = err
+		return
+	}
+
+	if f, err = w.zw.Create(name + ".csv"); err != nil {
+		w.err = err
+		return
+	}
+	cw := csv.NewWriter(f)
+	cw.Write(header)
+	cw.WriteAll(rows)
+	w.err = cw.Error()
+}
+
+// copy adds a file from private storage. Files missing from the storage are skipped.
+func (w *exportWriter) copy(ctx context.Context, store storage.PrivateStorage, key, name string) error {
+	if w.err != nil {
+		return w.err
+	}
+	src, err := store.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := w.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+func exportUint(v uint) string {
+	return strconv.FormatUint(uint64(v), 10)
+}
+
+func exportUintPtr(v *uint) string {
+	if v == nil {
+		return ""
+	}
+	return exportUint(*v)
+}
+
+func exportString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func exportFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func exportFloatPtr(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return exportFloat(*v)
+}
+
+func exportDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func exportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func exportTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return exportTime(*t)
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"fmt"
+	"log"
+	"time"
+)
+
+const feedBackfillSize = 50 // Activities copied into a feed when someone follows a user
+
+// FeedStrategy decides when activities reach the home feeds of followers
+type FeedStrategy interface {
+	Publish(activity *models.Activity) error
+	Feed(userID, beforeID uint, limit int) ([]models.FeedItem, error)
+	Followed(followerID, followingID uint) error
+	Unfollowed(followerID, followingID uint) error
+}
+
+// FanOutOnRead stores each activity once and collects followed users' activities when a feed is read.
+// Writes are cheap and follows take effect immediately.
+type FanOutOnRead struct {
+	repo *repository.UserRepository
+}
+
+func NewFanOutOnRead(repo *repository.UserRepository) *FanOutOnRead {
+	return &FanOutOnRead{repo: repo}
+}
+
+func (f *FanOutOnRead) Publish(activity *models.Activity) error {
+	return f.repo.CreateActivity(activity)
+}
+
+func (f *FanOutOnRead) Feed(userID, beforeID uint, limit int) ([]models.FeedItem, error) {
+	return f.repo.GetFollowedActivities(userID, beforeID, limit)
+}
+
+func (f *FanOutOnRead) Followed(followerID, followingID uint) error   { return nil }
+func (f *FanOutOnRead) Unfollowed(followerID, followingID uint) error { return nil }
+
+// FanOutOnWrite copies each activity into every follower's feed when it happens.
+// Reads are a single indexed lookup, which pays off once users follow many people.
+type FanOutOnWrite struct {
+	repo *repository.UserRepository
+}
+
+func NewFanOutOnWrite(repo *repository.UserRepository) *FanOutOnWrite {
+	return &FanOutOnWrite{repo: repo}
+}
+
+func (f *FanOutOnWrite) Publish(activity *models.Activity) error {
+	return f.repo.CreateActivityWithFanOut(activity)
+}
+
+func (f *FanOutOnWrite) Feed(userID, beforeID uint, limit int) ([]models.FeedItem, error) {
+	return f.repo.GetFeedEntries(userID, beforeID, limit)
+}
+
+func (f *FanOutOnWrite) Followed(followerID, followingID uint) error {
+	return f.repo.BackfillFeed(followerID, followingID, feedBackfillSize)
+}
+
+func (f *FanOutOnWrite) Unfollowed(followerID, followingID uint) error {
+	return f.repo.RemoveFromFeed(followerID, followingID)
+}
+
+// NewFeedStrategy picks the strategy by name: "write" fans out on write, anything else on read
+func NewFeedStrategy(repo *repository.UserRepository, name string) FeedStrategy {
+	if name == "write" {
+		return NewFanOutOnWrite(repo)
+	}
+	return NewFanOutOnRead(repo)
+}
+
+type FeedService struct {
+	repo     *repository.UserRepository
+	strategy FeedStrategy
+}
+
+func NewFeedService(repo *repository.UserRepository, strategy FeedStrategy) *FeedService {
+	return &FeedService{repo: repo, strategy: strategy}
+}
+
+// Publish adds the activity to the feeds. A failure is only logged, since the action itself already succeeded.
+func (s *FeedService) Publish(activity models.Activity) {
+	activity.CreatedAt = time.Now()
+	if err := s.strategy.Publish(&activity); err != nil {
+		log.Printf("Failed to publish %s activity of user %d: %v", activity.Type, activity.UserID, err)
+	}
+}
+
+func (s *FeedService) PublishPost(post *models.Post) {
+	s.Publish(models.Activity{UserID: post.UserID, Type: "post", RefID: post.ID, Title: post.Title})
+}
+
+func (s *FeedService) PublishAchievement(achievement *models.Achievement) {
+	s.Publish(models.Activity{UserID: achievement.UserID, Type: "achievement", RefID: achievement.ID, Title: achievement.Name})
+}
+
+// PublishMilestones announces a new daily steps record and reaching the target weight
+func (s *FeedService) PublishMilestones(user *models.User, progress *models.Progress) error {
+	// Only the user's own progress can announce their milestones
+	if progress.UserID != user.ID {
+		return nil
+	}
+	if progress.Steps > 0 {
+		best, err := s.repo.MaxSteps(user.ID, progress.ID)
+		if err != nil {
+			return err
+		}
+		// The first entry sets a baseline rather than a record
+		if best > 0 && progress.Steps > best {
+			s.Publish(models.Activity{
+				UserID: user.ID,
+				Type:   "milestone",
+				Kind:   "step_record",
+				Title:  fmt.Sprintf("New record: %d steps in a day", progress.Steps),
+				Value:  float64(progress.Steps),
+			})
+		}
+	}
+
+	if progress.Weight > 0 && user.TargetWeight > 0 {
+		previous, err := s.repo.PreviousWeight(progress)
+		if err != nil {
+			return err
+		}
+		// Only crossing the target counts, so staying at it doesn't repeat the milestone
+		if reachedTarget(user, progress.Weight) && (previous == 0 || !reachedTarget(user, previous)) {
+			s.Publish(models.Activity{
+				UserID: user.ID,
+				Type:   "milestone",
+				Kind:   "weight_goal",
+				Title:  fmt.Sprintf("Reached the target weight of %.1f kg", user.TargetWeight),
+				Value:  progress.Weight,
+			})
+		}
+	}
+	return nil
+}
+
+func reachedTarget(user *models.User, weight float64) bool {
+	if user.Goal == "weight_gain" {
+		return weight >= user.TargetWeight
+	}
+	return weight <= user.TargetWeight
+}
+
+// Feed returns a page of the user's home feed, newest first, starting before the given activity
+func (s *FeedService) Feed(userID, beforeID uint, limit int) ([]models.FeedItem, error) {
+	return s.strategy.Feed(userID, beforeID, limit)
+}
+
+func (s *FeedService) Followed(followerID, followingID uint) error {
+	return s.strategy.Followed(followerID, followingID)
+}
+
+func (s *FeedService) Unfollowed(followerID, followingID uint) error {
+	return s.strategy.Unfollowed(followerID, followingID)
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/image/draw"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const maxImagePixels = 40_000_000 // Refuse decompression bombs before decoding
+
+var (
+	ErrUnsupportedImage = errors.New("only JPEG and PNG images are supported")
+	ErrImageTooLarge    = errors.New("image dimensions are too large")
+)
+
+// ProcessedImage is an upload re-encoded without metadata, plus its thumbnail
+type ProcessedImage struct {
+	Data        []byte
+	Thumbnail   []byte
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+// ProcessImage validates the upload by its content, not its file name, and re-encodes it.
+// Re-encoding drops EXIF and other metadata such as GPS coordinates, so the EXIF orientation
+// is applied to the pixels first.
+// The thumbnail fits into a thumbSize x thumbSize box.
+func ProcessImage(data []byte, thumbSize int) (*ProcessedImage, error) {
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return nil, ErrUnsupportedImage
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	original, err := encodeImage(img, contentType)
+	if err != nil {
+		return nil, err
+	}
+	thumbnail, err := encodeImage(resizeToFit(img, thumbSize), contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := ".jpg"
+	if contentType == "image/png" {
+		ext = ".png"
+	}
+	return &ProcessedImage{
+		Data:        original,
+		Thumbnail:   thumbnail,
+		ContentType: contentType,
+		Ext:         ext,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}, nil
+}
+
+// resizeToFit scales the image down to fit into a size x size box, keeping the aspect ratio
+func resizeToFit(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	if w >= h {
+		h = max(h*size/w, 1)
+		w = size
+	} else {
+		w = max(w*size/h, 1)
+		h = size
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
+
+func encodeImage(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	}
+	return buf.Bytes(), err
+}
+
+// ResizeImage re-encodes an image from ProcessImage scaled down to fit into a size x size box
+func ResizeImage(processed *ProcessedImage, size int) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(processed.Data))
+	if err != nil {
+		return nil, err
+	}
+	return encodeImage(resizeToFit(img, size), processed.ContentType)
+}
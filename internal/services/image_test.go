@@ -0,0 +1,97 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// jpegWithOrientation encodes a 40x20 white JPEG with a red top-left corner and the given EXIF orientation
+func jpegWithOrientation(t *testing.T, orientation uint16) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.RGBA{255, 255, 255, 255}
+			if x < 10 && y < 10 {
+				c = color.RGBA{255, 0, 0, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112) // Orientation, SHORT, one value
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	data = binary.BigEndian.AppendUint16(data, uint16(len(segment)+2))
+	data = append(data, segment...)
+	return append(data, encoded.Bytes()[2:]...)
+}
+
+func isRed(img image.Image, x, y int) bool {
+	r, g, b, _ := img.At(x, y).RGBA()
+	return r > 0xC000 && g < 0x4000 && b < 0x4000
+}
+
+func TestProcessImageAppliesOrientation(t *testing.T) {
+	tests := []struct {
+		orientation   uint16
+		width, height int
+		redX, redY    int // A pixel inside the red corner once upright
+	}{
+		{1, 40, 20, 5, 5},
+		{2, 40, 20, 35, 5},
+		{3, 40, 20, 35, 15},
+		{4, 40, 20, 5, 15},
+		{5, 20, 40, 5, 5},
+		{6, 20, 40, 15, 5},
+		{7, 20, 40, 15, 35},
+		{8, 20, 40, 5, 35},
+	}
+	for _, tt := range tests {
+		data := jpegWithOrientation(t, tt.orientation)
+		if got := jpegOrientation(data); got != int(tt.orientation) {
+			t.Fatalf("orientation %d: read %d", tt.orientation, got)
+		}
+		processed, err := ProcessImage(data, 10)
+		if err != nil {
+			t.Fatalf("orientation %d: %v", tt.orientation, err)
+		}
+		if processed.Width != tt.width || processed.Height != tt.height {
+			t.Errorf("orientation %d: got %dx%d, want %dx%d", tt.orientation, processed.Width, processed.Height, tt.width, tt.height)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(processed.Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isRed(img, tt.redX, tt.redY) {
+			t.Errorf("orientation %d: pixel %d,%d is not red", tt.orientation, tt.redX, tt.redY)
+		}
+	}
+}
+
+func TestJpegOrientationWithoutExif(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := jpegOrientation(encoded.Bytes()); got != 1 {
+		t.Errorf("got %d, want 1", got)
+	}
+	if got := jpegOrientation([]byte("not a jpeg")); got != 1 {
+		t.Errorf("got %d, want 1", got)
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"errors"
+	"time"
+)
+
+var (
+	ErrUnknownPeriod = errors.New("unknown period")
+	ErrUnknownScope  = errors.New("unknown scope")
+	ErrNotInGroup    = errors.New("not a member of the group")
+)
+
+type LeaderboardService struct {
+	repo *repository.UserRepository
+}
+
+func NewLeaderboardService(repo *repository.UserRepository) *LeaderboardService {
+	return &LeaderboardService{repo: repo}
+}
+
+// Leaderboard is one page of rankings plus the requesting user's own position
+type Leaderboard struct {
+	Period      string                    `json:"period"`
+	Scope       string                    `json:"scope"`
+	GroupID     uint                      `json:"group_id,omitempty"`
+	PeriodStart time.Time                 `json:"period_start"`
+	Entries     []models.LeaderboardEntry `json:"entries"`
+	Total       int64                     `json:"total"`
+	Page        int                       `json:"page"`
+	Limit       int                       `json:"limit"`
+	Me          *models.LeaderboardEntry  `json:"me,omitempty"`
+}
+
+// Get ranks users by points in the current week, month or all time,
+// either globally, among the user's friends or among the members of a group they belong to
+func (s *LeaderboardService) Get(userID uint, period, scope string, groupID uint, page, limit int) (*Leaderboard, error) {
+	start, err := repository.PeriodStart(period, time.Now())
+	if err != nil {
+		return nil, ErrUnknownPeriod
+	}
+
+	var userIDs []uint
+	switch scope {
+	case "global":
+	case "friends":
+		friends, err := s.repo.FriendIDs(userID)
+		if err != nil {
+			return nil, err
+		}
+		userIDs = append(friends, userID)
+	case "group":
+		isMember, err := s.repo.IsGroupMember(groupID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !isMember {
+			return nil, ErrNotInGroup
+		}
+		if userIDs, err = s.repo.GroupMemberIDs(groupID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnknownScope
+	}
+
+	entries, total, err := s.repo.GetLeaderboard(period, start, userIDs, limit, (page-1)*limit)
+	if err != nil {
+		return nil, err
+	}
+	me, err := s.repo.GetLeaderboardEntry(period, start, userIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Leaderboard{
+		Period:      period,
+		Scope:       scope,
+		GroupID:     groupID,
+		PeriodStart: start,
+		Entries:     entries,
+		Total:       total,
+		Page:        page,
+		Limit:       limit,
+		Me:          me,
+	}, nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"fmt"
+	"math"
+)
+
+type LevelService struct {
+	repo   *repository.UserRepository
+	events EventBus
+	base   int
+	growth float64
+}
+
+func NewLevelService(repo *repository.UserRepository, events EventBus, base int, growth float64) *LevelService {
+	return &LevelService{repo: repo, events: events, base: base, growth: growth}
+}
+
+// LevelInfo describes where a user stands on the XP curve
+type LevelInfo struct {
+	Level          int `json:"level"`
+	TotalXP        int `json:"total_xp"`
+	XPIntoLevel    int `json:"xp_into_level"`
+	XPForNextLevel int `json:"xp_for_next_level"` // XP the current level takes in total
+	XPToNextLevel  int `json:"xp_to_next_level"`  // XP still missing for the next level
+}
+
+// LevelFor places the XP total on the curve. Level n needs base * growth^(n-1) XP to complete.
+func (s *LevelService) LevelFor(totalXP int) LevelInfo {
+	level := 1
+	remaining := max(totalXP, 0)
+	needed := s.base
+	for remaining >= needed {
+		remaining -= needed
+		level++
+		needed = int(math.Round(float64(s.base) * math.Pow(s.growth, float64(level-1))))
+	}
+	return LevelInfo{
+		Level:          level,
+		TotalXP:        totalXP,
+		XPIntoLevel:    remaining,
+		XPForNextLevel: needed,
+		XPToNextLevel:  needed - remaining,
+	}
+}
+
+// UserLevel returns the level of the user from the cached XP total
+func (s *LevelService) UserLevel(userID uint) (LevelInfo, error) {
+	total, err := s.repo.GetXP(userID)
+	if err != nil {
+		return LevelInfo{}, err
+	}
+	return s.LevelFor(total), nil
+}
+
+// NotifyLevelUp leaves a notification in the user's inbox and pushes it as a "level_up" event
+func (s *LevelService) NotifyLevelUp(userID uint, level int) error {
+	notification := models.Notification{
+		UserID:  userID,
+		ActorID: userID,
+		Type:    "level_up",
+		Text:    fmt.Sprintf("You reached level %d", level),
+	}
+	if err := s.repo.CreateNotification(&notification); err != nil {
+		return err
+	}
+	s.events.Publish(userID, Event{Type: "level_up", Data: notification})
+	return nil
+}
+
+// LeveledUp reports the new level when going from before to after XP crosses a level boundary
+func (s *LevelService) LeveledUp(before, after int) (int, bool) {
+	oldLevel := s.LevelFor(before).Level
+	newLevel := s.LevelFor(after).Level
+	return newLevel, newLevel > oldLevel
+}
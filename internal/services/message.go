@@ -0,0 +1,84 @@
+package services
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxMessageLength = 2000
+
+var (
+	ErrInvalidMessage = errors.New("message must be 1 to 2000 characters")
+	ErrCannotMessage  = errors.New("you cannot message this user")
+)
+
+type MessageService struct {
+	repo   *repository.UserRepository
+	events EventBus
+}
+
+func NewMessageService(repo *repository.UserRepository, events EventBus) *MessageService {
+	return &MessageService{repo: repo, events: events}
+}
+
+// Send stores the message and pushes it to the recipient if they are connected.
+// Either user blocking the other stops the conversation.
+func (s *MessageService) Send(senderID, recipientID uint, content string) (*models.Message, error) {
+	content = strings.TrimSpace(content)
+	if content == "" || utf8.RuneCountInString(content) > maxMessageLength {
+		return nil, ErrInvalidMessage
+	}
+	if senderID == recipientID {
+		return nil, ErrCannotMessage
+	}
+	if _, err := s.repo.FindByID(recipientID); err != nil {
+		return nil, ErrCannotMessage
+	}
+	blocked, err := s.repo.IsBlockedBetween(senderID, recipientID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrCannotMessage
+	}
+
+	conversation, err := s.repo.FindOrCreateConversation(senderID, recipientID)
+	if err != nil {
+		return nil, err
+	}
+	message := models.Message{
+		ConversationID: conversation.ID,
+		SenderID:       senderID,
+		RecipientID:    recipientID,
+		Content:        content,
+		CreatedAt:      time.Now(),
+	}
+	if err := s.repo.CreateMessage(&message); err != nil {
+		return nil, err
+	}
+	s.events.Publish(recipientID, Event{Type: "message", Data: message})
+	return &message, nil
+}
+
+// MarkRead sets the read receipts of the reader's unread messages and tells the other user about it
+func (s *MessageService) MarkRead(conversation *models.Conversation, readerID uint) (int64, error) {
+	now := time.Now()
+	count, err := s.repo.MarkMessagesRead(conversation.ID, readerID, now)
+	if err != nil || count == 0 {
+		return count, err
+	}
+	peerID := conversation.UserLowID
+	if peerID == readerID {
+		peerID = conversation.UserHighID
+	}
+	s.events.Publish(peerID, Event{Type: "message_read", Data: map[string]interface{}{
+		"conversation_id": conversation.ID,
+		"reader_id":       readerID,
+		"read_at":         now,
+	}})
+	return count, nil
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrInvalidModeration  = errors.New("invalid moderation action")
+	ErrModerationNotFound = errors.New("moderation target not found")
+	ErrModerationDenied   = errors.New("only admins can warn or suspend moderators and admins")
+)
+
+type FilterAction int
+
+const (
+	FilterAllow  FilterAction = iota
+	FilterFlag                // Publish, but queue for moderator review
+	FilterReject              // Refuse to publish
+)
+
+type FilterVerdict struct {
+	Action FilterAction
+	Reason string
+}
+
+// ContentFilter screens user text before it is published. Filters are combined and the strictest verdict wins.
+type ContentFilter interface {
+	Check(text string) FilterVerdict
+}
+
+var defaultRejectWords = []string{
+	// English
+	"fuck*", "motherfuck*", "shit", "shitty", "bullshit", "cunt*", "bitch*", "asshole*", "whore*", "nigger*", "faggot*",
+	// Russian
+	"хуй*", "хуе*", "хуё*", "хуя*", "пизд*", "ебать", "ебан*", "ебал*", "ебну*", "заеб*", "выеб*", "уеб*",
+	"бляд*", "блять", "сука", "суки", "сучк*", "мудак*", "мудил*", "пидор*", "пидар*", "гандон*", "шлюх*",
+	// Uzbek, Latin and Cyrillic script
+	"jalab*", "qanjiq*", "qotaq*", "qo'toq*", "sikaman", "sikay*", "sikib*", "dalbayob*", "gandon*",
+	"жалаб*", "қанжиқ*", "канжик*", "қўтоқ*", "сикаман", "сикай*", "далбаёб*",
+}
+
+var defaultFlagWords = []string{
+	// English
+	"idiot*", "stupid", "moron*", "retard*", "loser*",
+	// Russian
+	"идиот*", "дебил*", "тупой", "тупая", "урод*", "дура", "дурак*",
+	// Uzbek, Latin and Cyrillic script
+	"ahmoq*", "tentak*", "haromi", "xaromi", "аҳмоқ*", "ахмок*", "тентак*", "ҳароми", "хароми",
+}
+
+// WordListFilter rejects or flags text containing listed words. A trailing "*" matches every word
+// starting with the entry, which covers Russian and Uzbek inflections. Entries without it also
+// match English plural and verb endings (-s, -es, -ed, -ing).
+type WordListFilter struct {
+	reject, flag wordList
+}
+
+type wordList struct {
+	words    map[string]bool
+	prefixes []string
+}
+
+func NewWordListFilter(reject, flag []string) *WordListFilter {
+	return &WordListFilter{reject: newWordList(reject), flag: newWordList(flag)}
+}
+
+// NewDefaultWordListFilter ships a word list covering Uzbek, Russian and English profanity and insults
+func NewDefaultWordListFilter() *WordListFilter {
+	return NewWordListFilter(defaultRejectWords, defaultFlagWords)
+}
+
+func newWordList(entries []string) wordList {
+	list := wordList{words: make(map[string]bool)}
+	for _, entry := range entries {
+		if word, ok := strings.CutSuffix(entry, "*"); ok {
+			list.prefixes = append(list.prefixes, normalizeWord(word))
+		} else {
+			list.words[normalizeWord(entry)] = true
+		}
+	}
+	return list
+}
+
+func (l wordList) match(word string) bool {
+	if l.words[word] {
+		return true
+	}
+	for _, stem := range englishStems(word) {
+		if l.words[stem] {
+			return true
+		}
+	}
+	for _, prefix := range l.prefixes {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// englishStems returns the word without an English plural or verb ending: "shits", "shitting" -> "shit"
+func englishStems(word string) []string {
+	var stems []string
+	for _, suffix := range []string{"s", "es", "ed", "ing"} {
+		stem, ok := strings.CutSuffix(word, suffix)
+		if !ok || len(stem) < 2 {
+			continue
+		}
+		stems = append(stems, stem)
+		// The final consonant doubles before -ed and -ing
+		if (suffix == "ed" || suffix == "ing") && stem[len(stem)-1] == stem[len(stem)-2] {
+			stems = append(stems, stem[:len(stem)-1])
+		}
+	}
+	return stems
+}
+
+func (f *WordListFilter) Check(text string) FilterVerdict {
+	verdict := FilterVerdict{Action: FilterAllow}
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !isApostrophe(r)
+	})
+	for _, word := range words {
+		word = normalizeWord(word)
+		if f.reject.match(word) {
+			return FilterVerdict{Action: FilterReject, Reason: "Contains prohibited language"}
+		}
+		if f.flag.match(word) {
+			verdict = FilterVerdict{Action: FilterFlag, Reason: "May contain insults"}
+		}
+	}
+	return verdict
+}
+
+// Apostrophes are part of Uzbek Latin letters (o‘, g‘) and are typed in many ways
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '‘' || r == '’' || r == 'ʻ' || r == 'ʼ' || r == '`'
+}
+
+// Latin letters that look like Cyrillic ones, used to dodge filters in Cyrillic words
+var cyrillicLookalikes = strings.NewReplacer(
+	"a", "а", "e", "е", "o", "о", "p", "р", "c", "с", "x", "х", "y", "у", "k", "к", "m", "м", "t", "т", "h", "н", "b", "в",
+)
+
+// Digits standing in for Latin letters
+var latinLeetspeak = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t")
+
+// normalizeWord folds the spellings people use to get around word lists
+func normalizeWord(word string) string {
+	word = strings.ToLower(word)
+	word = strings.Map(func(r rune) rune {
+		if isApostrophe(r) {
+			return -1
+		}
+		if r == 'ё' {
+			return 'е'
+		}
+		return r
+	}, word)
+
+	hasCyrillic := false
+	for _, r := range word {
+		if unicode.Is(unicode.Cyrillic, r) {
+			hasCyrillic = true
+			break
+		}
+	}
+	if hasCyrillic {
+		return cyrillicLookalikes.Replace(word)
+	}
+	return latinLeetspeak.Replace(word)
+}
+
+// ModerationRequest is a moderator's decision about a post, comment or user
+type ModerationRequest struct {
+	Action     string `json:"action" binding:"required"`      // "hide", "unhide", "delete", "warn", "suspend" or "dismiss"
+	TargetType string `json:"target_type" binding:"required"` // "post", "comment" or "user"
+	TargetID   uint   `json:"target_id" binding:"required"`
+	Reason     string `json:"reason"`
+	Days       int    `json:"days"` // Length of a suspension
+}
+
+var contentActions = map[string]bool{"hide": true, "unhide": true, "delete": true, "warn": true, "suspend": true, "dismiss": true}
+
+var userActions = map[string]bool{"warn": true, "suspend": true}
+
+type ModerationService struct {
+	repo    *repository.UserRepository
+	filters []ContentFilter
+}
+
+func NewModerationService(repo *repository.UserRepository, filters ...ContentFilter) *ModerationService {
+	return &ModerationService{repo: repo, filters: filters}
+}
+
+// Screen runs the texts through every filter and returns the strictest verdict
+func (s *ModerationService) Screen(texts ...string) FilterVerdict {
+	text := strings.Join(texts, "\n")
+	verdict := FilterVerdict{Action: FilterAllow}
+	for _, filter := range s.filters {
+		if v := filter.Check(text); v.Action > verdict.Action {
+			verdict = v
+		}
+	}
+	return verdict
+}
+
+// RecordRejection audits content the filter refused to publish
+func (s *ModerationService) RecordRejection(userID uint, targetType string, verdict FilterVerdict) {
+	err := s.repo.AddModerationAction(&models.ModerationAction{
+		UserID:     userID,
+		Action:     "filter_reject",
+		TargetType: targetType,
+		Reason:     verdict.Reason,
+	})
+	if err != nil {
+		log.Printf("Failed to audit filter rejection for user %d: %v", userID, err)
+	}
+}
+
+// FlagForReview puts published content the filter doubts into the moderator queue
+func (s *ModerationService) FlagForReview(userID uint, targetType string, targetID uint, verdict FilterVerdict) {
+	err := s.repo.CreateReport(&models.Report{TargetType: targetType, TargetID: targetID, Reason: "Automatic filter: " + verdict.Reason})
+	if err == nil {
+		err = s.repo.AddModerationAction(&models.ModerationAction{
+			UserID:     userID,
+			Action:     "filter_flag",
+			TargetType: targetType,
+			TargetID:   targetID,
+			Reason:     verdict.Reason,
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to flag %s %d for review: %v", targetType, targetID, err)
+	}
+}
+
+// Moderate validates and applies a moderator's decision. Warnings and suspensions
+// on a post or comment apply to its author.
+func (s *ModerationService) Moderate(moderatorID uint, req ModerationRequest) (*models.ModerationAction, error) {
+	action := &models.ModerationAction{
+		ModeratorID: &moderatorID,
+		Action:      req.Action,
+		TargetType:  req.TargetType,
+		TargetID:    req.TargetID,
+		Reason:      req.Reason,
+	}
+
+	switch req.TargetType {
+	case "post":
+		post, err := s.repo.GetPostByID(req.TargetID)
+		if err != nil {
+			return nil, ErrModerationNotFound
+		}
+		action.UserID = post.UserID
+	case "comment":
+		comment, err := s.repo.GetCommentByID(req.TargetID)
+		if err != nil {
+			return nil, ErrModerationNotFound
+		}
+		action.UserID = comment.UserID
+	case "user":
+		if _, err := s.repo.FindByID(req.TargetID); err != nil {
+			return nil, ErrModerationNotFound
+		}
+		action.UserID = req.TargetID
+	default:
+		return nil, fmt.Errorf("%w: target_type must be post, comment or user", ErrInvalidModeration)
+	}
+
+	if (req.TargetType == "user" && !userActions[req.Action]) || !contentActions[req.Action] {
+		return nil, fmt.Errorf("%w: %q can't be applied to a %s", ErrInvalidModeration, req.Action, req.TargetType)
+	}
+	if req.Action == "suspend" {
+		if req.Days < 1 {
+			return nil, fmt.Errorf("%w: days must be at least 1", ErrInvalidModeration)
+		}
+		until := time.Now().AddDate(0, 0, req.Days)
+		action.Until = &until
+	}
+	if req.Action == "warn" || req.Action == "suspend" {
+		if action.UserID == moderatorID {
+			return nil, fmt.Errorf("%w: moderators can't warn or suspend themselves", ErrInvalidModeration)
+		}
+		if err := s.checkStaffTarget(moderatorID, action.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.Moderate(action); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
+
+// checkStaffTarget only lets admins act against moderators and admins
+func (s *ModerationService) checkStaffTarget(moderatorID, userID uint) error {
+	target, err := s.repo.FindByID(userID)
+	if err != nil {
+		return ErrModerationNotFound
+	}
+	if target.Role != "moderator" && target.Role != "admin" {
+		return nil
+	}
+	moderator, err := s.repo.FindByID(moderatorID)
+	if err != nil {
+		return err
+	}
+	if moderator.Role != "admin" {
+		return ErrModerationDenied
+	}
+	return nil
+}
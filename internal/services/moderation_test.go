@@ -0,0 +1,60 @@
+package services
+
+import "testing"
+
+func TestNormalizeWord(t *testing.T) {
+	tests := []struct {
+		word, want string
+	}{
+		{"Idiot", "idiot"},
+		{"1d10t", "idiot"},
+		{"5h1t", "shit"},
+		{"qo‘toq", "qotoq"},
+		{"qoʻtoq", "qotoq"},
+		{"Ёлка", "елка"},
+		{"cyкa", "сука"}, // Latin "c" and "y", Cyrillic rest
+		{"хyй", "хуй"},
+		{"h3ll0", "hello"},
+	}
+	for _, tt := range tests {
+		if got := normalizeWord(tt.word); got != tt.want {
+			t.Errorf("normalizeWord(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestWordListFilter(t *testing.T) {
+	filter := NewDefaultWordListFilter()
+	tests := []struct {
+		text string
+		want FilterAction
+	}{
+		{"Great workout today!", FilterAllow},
+		{"", FilterAllow},
+		{"This is shit", FilterReject},
+		{"SHIT happens", FilterReject},
+		{"sh1t", FilterReject},
+		{"all these shits", FilterReject},
+		{"he was shitting me", FilterReject},
+		{"fucking hard set", FilterReject},
+		{"you are stupid", FilterFlag},
+		{"stupids everywhere", FilterFlag},
+		{"idiots", FilterFlag},
+		{"what an idiot, shit", FilterReject},
+		{"сука", FilterReject},
+		{"cyкa", FilterReject},
+		{"пиздец", FilterReject},
+		{"ты дурак", FilterFlag},
+		{"qo‘toq", FilterReject},
+		{"ahmoqlar", FilterFlag},
+		// Stems must not catch unrelated words
+		{"shitake mushrooms", FilterAllow},
+		{"stupidity", FilterAllow},
+		{"sukachev", FilterAllow},
+	}
+	for _, tt := range tests {
+		if got := filter.Check(tt.text); got.Action != tt.want {
+			t.Errorf("Check(%q) = %v, want %v", tt.text, got.Action, tt.want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"diplomIshi/internal/storage"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	photoDisplaySize   = 1280
+	photoThumbnailSize = 320
+)
+
+var (
+	photoPoses = []string{"front", "side", "back"}
+
+	ErrUnknownPose = errors.New("pose must be front, side or back")
+)
+
+type PhotoService struct {
+	repo      *repository.UserRepository
+	streakSvc *StreakService
+	store     storage.PrivateStorage
+	urlTTL    time.Duration
+}
+
+func NewPhotoService(repo *repository.UserRepository, streakSvc *StreakService, store storage.PrivateStorage, urlTTL time.Duration) *PhotoService {
+	return &PhotoService{repo: repo, streakSvc: streakSvc, store: store, urlTTL: urlTTL}
+}
+
+func validPose(pose string) bool {
+	for _, p := range photoPoses {
+		if p == pose {
+			return true
+		}
+	}
+	return false
+}
+
+// CanView reports whether the viewer may see the owner's private progress: the owner and the coaches they authorized can
+func (s *PhotoService) CanView(viewerID, ownerID uint) (bool, error) {
+	if viewerID == ownerID {
+		return true, nil
+	}
+	return s.repo.IsCoachOf(viewerID, ownerID)
+}
+
+// Upload stores the uploaded file untouched under photos/originals and its display and thumbnail
+// variants, upright and without metadata, under photos/variants, then saves the photo row.
+// The original keeps its metadata, GPS included, so only the owner gets a link to it.
+// A zero date means today in the user's time zone.
+func (s *PhotoService) Upload(ctx context.Context, photo *models.ProgressPhoto, data []byte) error {
+	if !validPose(photo.Pose) {
+		return ErrUnknownPose
+	}
+	if photo.Date.IsZero() {
+		today, err := s.streakSvc.UserDay(photo.UserID, time.Now())
+		if err != nil {
+			return err
+		}
+		photo.Date = today
+	}
+	processed, err := ProcessImage(data, photoDisplaySize)
+	if err != nil {
+		return err
+	}
+	thumbnail, err := ResizeImage(processed, photoThumbnailSize)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	name := hex.EncodeToString(b)
+	photo.OriginalKey = fmt.Sprintf("photos/originals/%d/%s%s", photo.UserID, name, processed.Ext)
+	photo.DisplayKey = fmt.Sprintf("photos/variants/%d/%s_%d%s", photo.UserID, name, photoDisplaySize, processed.Ext)
+	photo.ThumbnailKey = fmt.Sprintf("photos/variants/%d/%s_%d%s", photo.UserID, name, photoThumbnailSize, processed.Ext)
+	photo.ContentType = processed.ContentType
+	photo.Width = processed.Width
+	photo.Height = processed.Height
+	photo.Size = len(data)
+
+	files := []struct {
+		key  string
+		data []byte
+	}{
+		{photo.OriginalKey, data},
+		{photo.DisplayKey, processed.Thumbnail},
+		{photo.ThumbnailKey, thumbnail},
+	}
+	for _, file := range files {
+		if err := s.store.Put(ctx, file.key, file.data, photo.ContentType); err != nil {
+			s.deleteFiles(ctx, photo)
+			return err
+		}
+	}
+	if err := s.repo.CreateProgressPhoto(photo); err != nil {
+		s.deleteFiles(ctx, photo)
+		return err
+	}
+	return s.Sign(ctx, photo, photo.UserID)
+}
+
+// Delete removes the photo row and then its files
+func (s *PhotoService) Delete(ctx context.Context, photo *models.ProgressPhoto) error {
+	if err := s.repo.DeleteProgressPhoto(photo); err != nil {
+		return err
+	}
+	s.deleteFiles(ctx, photo)
+	return nil
+}
+
+// Sign fills in the photo's URLs for the viewer. They are signed and expire after the configured time.
+// The original is only linked for its owner.
+func (s *PhotoService) Sign(ctx context.Context, photo *models.ProgressPhoto, viewerID uint) error {
+	var err error
+	if viewerID == photo.UserID {
+		if photo.OriginalURL, err = s.store.SignedURL(ctx, photo.OriginalKey, s.urlTTL); err != nil {
+			return err
+		}
+	}
+	if photo.URL, err = s.store.SignedURL(ctx, photo.DisplayKey, s.urlTTL); err != nil {
+		return err
+	}
+	photo.ThumbnailURL, err = s.store.SignedURL(ctx, photo.ThumbnailKey, s.urlTTL)
+	return err
+}
+
+// Compare pairs, for every pose (or only the given one), the photo taken closest to before with the one
+// taken closest to after. Zero dates mean the first and the latest photo of each pose.
+func (s *PhotoService) Compare(ctx context.Context, viewerID, userID uint, pose string, before, after time.Time) ([]models.PhotoPair, error) {
+	poses := photoPoses
+	if pose != "" {
+		if !validPose(pose) {
+			return nil, ErrUnknownPose
+		}
+		poses = []string{pose}
+	}
+	if before.IsZero() {
+		before = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	if after.IsZero() {
+		after = time.Now().AddDate(1, 0, 0)
+	}
+
+	pairs := []models.PhotoPair{}
+	for _, pose := range poses {
+		first, err := s.repo.NearestProgressPhoto(userID, pose, before)
+		if err != nil {
+			return nil, err
+		}
+		last, err := s.repo.NearestProgressPhoto(userID, pose, after)
+		if err != nil {
+			return nil, err
+		}
+		if first == nil || last == nil || first.ID == last.ID {
+			continue // Nothing to compare
+		}
+		if first.Date.After(last.Date) {
+			first, last = last, first
+		}
+		if err := s.Sign(ctx, first, viewerID); err != nil {
+			return nil, err
+		}
+		if err := s.Sign(ctx, last, viewerID); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, models.PhotoPair{
+			Pose:   pose,
+			Before: *first,
+			After:  *last,
+			Days:   int(last.Date.Sub(first.Date).Hours() / 24),
+		})
+	}
+	return pairs, nil
+}
+
+// deleteFiles removes the original and the variants, logging failures instead of returning them
+func (s *PhotoService) deleteFiles(ctx context.Context, photo *models.ProgressPhoto) {
+	for _, key := range []string{photo.OriginalKey, photo.DisplayKey, photo.ThumbnailKey} {
+		if err := s.store.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Failed to delete file %s: %v", key, err)
+		}
+	}
+}
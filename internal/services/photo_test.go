@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"diplomIshi/internal/storage"
+	"testing"
+	"time"
+)
+
+func TestPhotoCanViewOwnPhotos(t *testing.T) {
+	// The owner is let in before any lookup
+	s := NewPhotoService(nil, nil, nil, 0)
+	canView, err := s.CanView(7, 7)
+	if err != nil || !canView {
+		t.Errorf("CanView(owner) = %v, %v", canView, err)
+	}
+}
+
+func TestPhotoCanViewCoaches(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&models.User{}, &models.CoachClient{}); err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.NewUserRepository(db)
+	client := models.User{FullName: "photo test client", Password: "x"}
+	coach := models.User{FullName: "photo test coach", Password: "x", Role: "coach"}
+	former := models.User{FullName: "photo test former coach", Password: "x"}
+	stranger := models.User{FullName: "photo test stranger", Password: "x"}
+	for _, user := range []*models.User{&client, &coach, &former, &stranger} {
+		if err := repo.Create(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := []models.CoachClient{{CoachID: coach.ID, ClientID: client.ID}, {CoachID: former.ID, ClientID: client.ID}}
+	if err := db.Create(&links).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Delete(&links)
+		db.Delete(&models.User{}, []uint{client.ID, coach.ID, former.ID, stranger.ID})
+	})
+
+	s := NewPhotoService(repo, nil, nil, 0)
+	tests := []struct {
+		name          string
+		viewer, owner uint
+		want          bool
+	}{
+		{"coach of the client", coach.ID, client.ID, true},
+		{"linked user without the coach role", former.ID, client.ID, false},
+		{"stranger", stranger.ID, client.ID, false},
+		{"client of the coach", client.ID, coach.ID, false},
+	}
+	for _, tt := range tests {
+		canView, err := s.CanView(tt.viewer, tt.owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if canView != tt.want {
+			t.Errorf("%s: CanView = %v, want %v", tt.name, canView, tt.want)
+		}
+	}
+}
+
+func TestPhotoSignLinksOriginalForOwnerOnly(t *testing.T) {
+	store, err := storage.NewLocalPrivateStorage(t.TempDir(), "/private", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewPhotoService(nil, nil, store, time.Minute)
+	photo := models.ProgressPhoto{UserID: 7, OriginalKey: "o.jpg", DisplayKey: "d.jpg", ThumbnailKey: "t.jpg"}
+
+	// The original keeps its metadata, a coach only gets the variants
+	if err := s.Sign(context.Background(), &photo, 8); err != nil {
+		t.Fatal(err)
+	}
+	if photo.OriginalURL != "" || photo.URL == "" || photo.ThumbnailURL == "" {
+		t.Errorf("coach got original %q, display %q, thumbnail %q", photo.OriginalURL, photo.URL, photo.ThumbnailURL)
+	}
+
+	if err := s.Sign(context.Background(), &photo, 7); err != nil {
+		t.Fatal(err)
+	}
+	if photo.OriginalURL == "" {
+		t.Error("owner got no link to the original")
+	}
+}
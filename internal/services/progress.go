@@ -0,0 +1,178 @@
+package services
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrUnknownMetric = errors.New("unknown metric")
+	ErrUnknownBucket = errors.New("bucket must be day, week or month")
+	ErrInvalidRange  = errors.New("from must not be after to")
+
+	ErrInvalidMeasurement = errors.New("invalid measurement")
+)
+
+type ProgressService struct {
+	repo      *repository.UserRepository
+	streakSvc *StreakService
+}
+
+func NewProgressService(repo *repository.UserRepository, streakSvc *StreakService) *ProgressService {
+	return &ProgressService{repo: repo, streakSvc: streakSvc}
+}
+
+// SeriesChange compares the first and the last reading in the range
+type SeriesChange struct {
+	First   models.SeriesReading `json:"first"`
+	Last    models.SeriesReading `json:"last"`
+	Change  float64              `json:"change"`
+	Percent float64              `json:"percent"`
+}
+
+// ProgressSeries is a chart-ready view of one metric
+type ProgressSeries struct {
+	Metric  string                `json:"metric"`
+	Bucket  string                `json:"bucket"`
+	From    time.Time             `json:"from"`
+	To      time.Time             `json:"to"`
+	Span    int                   `json:"span"` // Buckets the trend line averages over
+	Buckets []models.SeriesBucket `json:"buckets"`
+	Change  *SeriesChange         `json:"change,omitempty"` // Nil when the range has no readings
+}
+
+// Series buckets the metric between from and to (both dates inclusive) and smooths the bucket
+// averages with an exponential moving average over span buckets
+func (s *ProgressService) Series(userID uint, metric, bucket string, from, to time.Time, span int) (*ProgressSeries, error) {
+	if !repository.IsSeriesMetric(metric) {
+		return nil, ErrUnknownMetric
+	}
+	if bucket != "day" && bucket != "week" && bucket != "month" {
+		return nil, ErrUnknownBucket
+	}
+	if from.After(to) {
+		return nil, ErrInvalidRange
+	}
+	end := to.AddDate(0, 0, 1)
+
+	buckets, err := s.repo.GetSeriesBuckets(userID, metric, bucket, from, end)
+	if err != nil {
+		return nil, err
+	}
+	smoothTrend(buckets, span)
+
+	series := &ProgressSeries{
+		Metric:  metric,
+		Bucket:  bucket,
+		From:    from,
+		To:      to,
+		Span:    span,
+		Buckets: buckets,
+	}
+	first, last, err := s.repo.GetSeriesEnds(userID, metric, from, end)
+	if err != nil {
+		return nil, err
+	}
+	if first != nil {
+		series.Change = seriesChange(*first, *last)
+	}
+	return series, nil
+}
+
+// smoothTrend fills in the buckets' trend, an exponential moving average of their averages over span buckets
+func smoothTrend(buckets []models.SeriesBucket, span int) {
+	alpha := 2 / float64(span+1)
+	var trend float64
+	for i := range buckets {
+		if i == 0 {
+			trend = buckets[i].Avg
+		} else {
+			trend = alpha*buckets[i].Avg + (1-alpha)*trend
+		}
+		buckets[i].Trend = round2(trend)
+		buckets[i].Avg = round2(buckets[i].Avg)
+	}
+}
+
+// seriesChange compares two readings. The percentage stays 0 when the first reading is 0.
+func seriesChange(first, last models.SeriesReading) *SeriesChange {
+	change := &SeriesChange{First: first, Last: last, Change: round2(last.Value - first.Value)}
+	if first.Value != 0 {
+		change.Percent = round2((last.Value - first.Value) / first.Value * 100)
+	}
+	return change
+}
+
+// Today returns the user's current day in their time zone, as midnight UTC
+func (s *ProgressService) Today(userID uint) (time.Time, error) {
+	return s.streakSvc.UserDay(userID, time.Now())
+}
+
+// LogMeasurement validates the measurement and merges it into the user's entry for its day.
+// A zero date means today in the user's time zone. The fields named in clear are emptied.
+func (s *ProgressService) LogMeasurement(measurement *models.Measurement, clear []string) error {
+	today, err := s.streakSvc.UserDay(measurement.UserID, time.Now())
+	if err != nil {
+		return err
+	}
+	if measurement.Date.IsZero() {
+		measurement.Date = today
+	}
+	if measurement.Date.After(today) {
+		return fmt.Errorf("%w: date is in the future", ErrInvalidMeasurement)
+	}
+
+	limits := []struct {
+		name     string
+		value    *float64
+		min, max float64
+	}{
+		{"waist", measurement.Waist, 20, 300},
+		{"hips", measurement.Hips, 20, 300},
+		{"chest", measurement.Chest, 20, 300},
+		{"neck", measurement.Neck, 10, 100},
+		{"arms", measurement.Arms, 5, 100},
+		{"thighs", measurement.Thighs, 10, 150},
+		{"body_fat", measurement.BodyFat, 1, 75},
+		{"resting_heart_rate", measurement.RestingHeartRate, 20, 250},
+	}
+	measured := false
+	for _, limit := range limits {
+		if limit.value == nil {
+			continue
+		}
+		if *limit.value < limit.min || *limit.value > limit.max {
+			return fmt.Errorf("%w: %s must be between %g and %g", ErrInvalidMeasurement, limit.name, limit.min, limit.max)
+		}
+		measured = true
+	}
+	for _, name := range clear {
+		known := false
+		for _, limit := range limits {
+			if limit.name != name {
+				continue
+			}
+			if limit.value != nil {
+				return fmt.Errorf("%w: %s is both sent and cleared", ErrInvalidMeasurement, name)
+			}
+			known = true
+		}
+		if !known {
+			return fmt.Errorf("%w: unknown measurement %s", ErrInvalidMeasurement, name)
+		}
+	}
+	if !measured && len(clear) == 0 {
+		return fmt.Errorf("%w: nothing was measured", ErrInvalidMeasurement)
+	}
+
+	measurement.ID = 0
+	return s.repo.SaveMeasurement(measurement, clear)
+}
+
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
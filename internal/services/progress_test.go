@@ -0,0 +1,104 @@
+package services
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"testing"
+	"time"
+)
+
+func TestSmoothTrend(t *testing.T) {
+	buckets := []models.SeriesBucket{{Avg: 10}, {Avg: 20}, {Avg: 30.126}}
+	// A span of 3 weighs each bucket by 2/(3+1) = 0.5
+	smoothTrend(buckets, 3)
+
+	want := []struct{ avg, trend float64 }{{10, 10}, {20, 15}, {30.13, 22.56}}
+	for i, w := range want {
+		if buckets[i].Avg != w.avg || buckets[i].Trend != w.trend {
+			t.Errorf("bucket %d = avg %g, trend %g, want avg %g, trend %g", i, buckets[i].Avg, buckets[i].Trend, w.avg, w.trend)
+		}
+	}
+}
+
+func TestSeriesChange(t *testing.T) {
+	change := seriesChange(models.SeriesReading{Value: 80}, models.SeriesReading{Value: 78.3})
+	if change.Change != -1.7 || change.Percent != -2.13 {
+		t.Errorf("change = %g (%g%%), want -1.7 (-2.13%%)", change.Change, change.Percent)
+	}
+
+	// Without a first value there is no percentage
+	change = seriesChange(models.SeriesReading{Value: 0}, models.SeriesReading{Value: 5})
+	if change.Change != 5 || change.Percent != 0 {
+		t.Errorf("change = %g (%g%%), want 5 (0%%)", change.Change, change.Percent)
+	}
+}
+
+func TestLogMeasurementMergesAndClears(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&models.User{}, &models.Measurement{}); err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.NewUserRepository(db)
+	user := models.User{FullName: "measurement test", Password: "x", WaistCircumference: 90}
+	if err := repo.Create(&user); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where("user_id = ?", user.ID).Delete(&models.Measurement{})
+		db.Delete(&user)
+	})
+	svc := NewProgressService(repo, NewStreakService(repo, NewCalculatorService(db)))
+
+	day1 := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	value := func(v float64) *float64 { return &v }
+	log := func(measurement models.Measurement, clear ...string) models.Measurement {
+		t.Helper()
+		measurement.UserID = user.ID
+		if err := svc.LogMeasurement(&measurement, clear); err != nil {
+			t.Fatal(err)
+		}
+		return measurement
+	}
+	profileWaist := func() float64 {
+		t.Helper()
+		found, err := repo.FindByID(user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return found.WaistCircumference
+	}
+
+	log(models.Measurement{Date: day1, Waist: value(85), Hips: value(100)})
+	merged := log(models.Measurement{Date: day1, Chest: value(95)})
+	if merged.Waist == nil || *merged.Waist != 85 || merged.Hips == nil || merged.Chest == nil || *merged.Chest != 95 {
+		t.Fatalf("merged entry = %+v, want waist, hips and chest", merged)
+	}
+	if waist := profileWaist(); waist != 85 {
+		t.Errorf("profile waist = %g, want 85", waist)
+	}
+
+	log(models.Measurement{Date: day2, Waist: value(84)})
+	if waist := profileWaist(); waist != 84 {
+		t.Errorf("profile waist = %g, want the latest 84", waist)
+	}
+
+	// Clearing the only field of day 2 removes its entry and the profile falls back to day 1
+	log(models.Measurement{Date: day2}, "waist")
+	if waist := profileWaist(); waist != 85 {
+		t.Errorf("profile waist = %g after clearing, want 85", waist)
+	}
+
+	cleared := log(models.Measurement{Date: day1}, "hips")
+	if cleared.Hips != nil || cleared.Waist == nil || cleared.Chest == nil {
+		t.Errorf("entry after clearing hips = %+v", cleared)
+	}
+
+	measurements, err := repo.GetMeasurements(user.ID, day1, day2.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(measurements) != 1 || !measurements[0].Date.Equal(day1) {
+		t.Errorf("got %d entries, want only day 1", len(measurements))
+	}
+}
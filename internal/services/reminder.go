@@ -3,25 +3,45 @@ package services
 import (
 	"diplomIshi/internal/models"
 	"diplomIshi/internal/repository"
+	"errors"
+	"fmt"
 	"github.com/robfig/cron/v3"
 	"log"
+	"sync"
+	"time"
 )
 
 type ReminderService struct {
 	repo    *repository.UserRepository
+	events  EventBus
 	cron    *cron.Cron
-	entries map[uint]cron.EntryID // Track cron entries by reminder ID
+	mu      sync.Mutex
+	entries map[uint]scheduledReminder // Track cron entries by reminder ID
 }
 
-func NewReminderService(repo *repository.UserRepository) *ReminderService {
+// scheduledReminder is a reminder's cron entry and the spec it was scheduled with
+type scheduledReminder struct {
+	entryID cron.EntryID
+	spec    string
+}
+
+func NewReminderService(repo *repository.UserRepository, events EventBus) *ReminderService {
 	return &ReminderService{
 		repo:    repo,
+		events:  events,
 		cron:    cron.New(),
-		entries: make(map[uint]cron.EntryID),
+		entries: make(map[uint]scheduledReminder),
 	}
 }
 
+// Start runs the scheduler. Reminders are created and disabled through any instance,
+// so every minute each instance also picks up the changes made through the others.
 func (s *ReminderService) Start() {
+	s.cron.AddFunc("@every 1m", func() {
+		if err := s.LoadAllReminders(); err != nil {
+			log.Println("Failed to sync reminders:", err)
+		}
+	})
 	s.cron.Start()
 }
 
@@ -29,19 +49,256 @@ func (s *ReminderService) Stop() {
 	s.cron.Stop()
 }
 
+// PrepareReminder validates the reminder schedule before it is saved.
+// A structured schedule is compiled into the cron spec stored in Time.
+// The user's time zone is filled in for scheduling and previews.
+func (s *ReminderService) PrepareReminder(reminder *models.Reminder) error {
+	user, err := s.repo.FindByID(reminder.UserID)
+	if err != nil {
+		return err
+	}
+	reminder.Timezone = user.Timezone
+	if reminder.Schedule != nil {
+		spec, err := CompileSchedule(reminder.Schedule)
+		if err != nil {
+			return err
+		}
+		reminder.Time = spec
+	}
+	if reminder.Time == "" {
+		return errors.New("either schedule or time is required")
+	}
+	if err := validateCondition(reminder.Condition); err != nil {
+		return err
+	}
+	_, err = ParseReminderSchedule(reminder)
+	return err
+}
+
+// Preview returns the next count fire times of the reminder.
+func (s *ReminderService) Preview(reminder *models.Reminder, count int) ([]time.Time, error) {
+	sched, err := ParseReminderSchedule(reminder)
+	if err != nil {
+		return nil, err
+	}
+	return NextRuns(sched, time.Now(), count), nil
+}
+
+// ScheduleReminder adds the reminder to the scheduler. A reminder that is already scheduled
+// is left alone, or rescheduled if its schedule changed.
 func (s *ReminderService) ScheduleReminder(reminder *models.Reminder) error {
-	entryID, err := s.cron.AddFunc(reminder.Time, func() {
-		// Simulate sending a push notification (replace with real implementation)
-		log.Printf("Reminder for User %d: %s", reminder.UserID, reminder.Message)
-		// Example: Integrate with FCM here
-	})
+	sched, err := ParseReminderSchedule(reminder)
 	if err != nil {
 		return err
 	}
-	s.entries[reminder.ID] = entryID
+	spec := cronSpec(reminder)
+	if reminder.Schedule != nil && reminder.Schedule.EndDate != nil {
+		spec += " until " + reminder.Schedule.EndDate.Format(time.RFC3339)
+	}
+
+	s.schedule(reminder, sched, spec)
 	return nil
 }
 
+// schedule adds the reminder's cron entry, replacing one with a different spec
+func (s *ReminderService) schedule(reminder *models.Reminder, sched cron.Schedule, spec string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.entries[reminder.ID]; ok {
+		if existing.spec == spec {
+			return
+		}
+		s.cron.Remove(existing.entryID)
+	}
+	entryID := s.cron.Schedule(sched, cron.FuncJob(func() {
+		s.fire(reminder, s.dueTime(reminder.ID))
+	}))
+	s.entries[reminder.ID] = scheduledReminder{entryID: entryID, spec: spec}
+}
+
+// LoadAllReminders schedules every active reminder, e.g. on startup of a new instance,
+// and drops the ones that are no longer active
+func (s *ReminderService) LoadAllReminders() error {
+	reminders, err := s.repo.GetActiveReminders()
+	if err != nil {
+		return err
+	}
+	active := make(map[uint]bool, len(reminders))
+	for i := range reminders {
+		active[reminders[i].ID] = true
+		if err := s.ScheduleReminder(&reminders[i]); err != nil {
+			log.Printf("Failed to schedule reminder %d: %v", reminders[i].ID, err)
+		}
+	}
+
+	s.mu.Lock()
+	var inactive []uint
+	for reminderID := range s.entries {
+		if !active[reminderID] {
+			inactive = append(inactive, reminderID)
+		}
+	}
+	s.mu.Unlock()
+	for _, reminderID := range inactive {
+		s.RemoveReminder(reminderID)
+	}
+	return nil
+}
+
+// dueTime returns the time the running occurrence of the reminder was scheduled for.
+// cron sets the entry's Prev to it before answering Entry, so it doesn't depend on this instance's clock.
+func (s *ReminderService) dueTime(reminderID uint) time.Time {
+	s.mu.Lock()
+	scheduled, ok := s.entries[reminderID]
+	s.mu.Unlock()
+	if ok {
+		if prev := s.cron.Entry(scheduled.entryID).Prev; !prev.IsZero() {
+			return prev
+		}
+	}
+	return time.Now().Truncate(time.Minute)
+}
+
+// fire checks the reminder condition and either sends the reminder or records why it was skipped.
+// Every instance runs its own cron, so the occurrence due at due is claimed in the database first
+// and only the instance that wins the claim delivers it.
+func (s *ReminderService) fire(reminder *models.Reminder, due time.Time) {
+	now := time.Now()
+	entry := models.ReminderLog{
+		ReminderID:  reminder.ID,
+		ScheduledAt: due,
+		UserID:      reminder.UserID,
+		Status:      "pending",
+		FiredAt:     now,
+	}
+
+	claimed, err := s.repo.ClaimReminderFire(&entry)
+	if err != nil {
+		log.Printf("Failed to claim reminder %d: %v", reminder.ID, err)
+		return
+	}
+	if !claimed {
+		return // Another instance is delivering this occurrence
+	}
+
+	// The reminder may have been disabled through another instance
+	current, err := s.repo.FindReminder(reminder.ID)
+	if err != nil || !current.Active {
+		s.RemoveReminder(reminder.ID)
+		entry.Status = "skipped"
+		entry.Reason = "Reminder disabled"
+		if err := s.repo.UpdateReminderLog(&entry); err != nil {
+			log.Printf("Failed to log reminder %d: %v", reminder.ID, err)
+		}
+		return
+	}
+
+	reason, err := s.skipReason(current, now)
+	if err != nil {
+		log.Printf("Failed to evaluate condition for reminder %d: %v", reminder.ID, err)
+	}
+	if reason != "" {
+		entry.Status = "skipped"
+		entry.Reason = reason
+	} else {
+		entry.Status = "sent"
+		// Simulate sending a push notification (replace with real implementation)
+		log.Printf("Reminder for User %d: %s", current.UserID, current.Message)
+		// Example: Integrate with FCM here
+		s.events.Publish(current.UserID, Event{Type: "reminder", Data: current})
+	}
+
+	if err := s.repo.UpdateReminderLog(&entry); err != nil {
+		log.Printf("Failed to log reminder %d: %v", reminder.ID, err)
+	}
+}
+
+// skipReason returns a non-empty reason when the reminder condition is not met.
+// "Today" is the current day in the user's time zone.
+func (s *ReminderService) skipReason(reminder *models.Reminder, now time.Time) (string, error) {
+	cond := reminder.Condition
+	if cond == nil {
+		return "", nil
+	}
+
+	startOfDay, err := s.userToday(reminder.UserID, now)
+	if err != nil {
+		return "", err
+	}
+	// Completions are counted rather than points: none are awarded once the daily cap is reached
+	day := time.Date(startOfDay.Year(), startOfDay.Month(), startOfDay.Day(), 0, 0, 0, 0, time.UTC)
+	switch cond.Type {
+	case "no_exercise_today":
+		count, err := s.repo.CountCompletionsBetween(reminder.UserID, "exercise", day, day)
+		if err != nil || count == 0 {
+			return "", err
+		}
+		return "Exercise already completed today", nil
+	case "no_meal_today":
+		count, err := s.repo.CountCompletionsBetween(reminder.UserID, "meal", day, day)
+		if err != nil || count == 0 {
+			return "", err
+		}
+		return "Meal already logged today", nil
+	case "no_diary_today":
+		count, err := s.repo.CountCompletionsOn(reminder.UserID, day)
+		if err != nil || count == 0 {
+			return "", err
+		}
+		return "Diary already filled in today", nil
+	case "no_progress_days":
+		last, err := s.repo.LastProgressDate(reminder.UserID)
+		if err != nil || last.IsZero() {
+			return "", err
+		}
+		if now.Sub(last) < time.Duration(cond.Days)*24*time.Hour {
+			return fmt.Sprintf("Progress logged on %s", last.Format("2006-01-02")), nil
+		}
+	case "points_below":
+		total, err := s.repo.SumPointsBetween(reminder.UserID, startOfDay, now)
+		if err != nil || total < int64(cond.Points) {
+			return "", err
+		}
+		return fmt.Sprintf("Already earned %d points today", total), nil
+	}
+	return "", nil
+}
+
+// userToday returns the start of the current day in the user's time zone
+func (s *ReminderService) userToday(userID uint, now time.Time) (time.Time, error) {
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc), nil
+}
+
+func validateCondition(cond *models.ReminderCondition) error {
+	if cond == nil {
+		return nil
+	}
+	switch cond.Type {
+	case "no_exercise_today", "no_meal_today", "no_diary_today":
+		return nil
+	case "no_progress_days":
+		if cond.Days <= 0 {
+			return errors.New("condition days must be positive")
+		}
+		return nil
+	case "points_below":
+		if cond.Points <= 0 {
+			return errors.New("condition points must be positive")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown condition type %q", cond.Type)
+}
+
 func (s *ReminderService) LoadReminders(userID uint) error {
 	reminders, err := s.repo.GetReminders(userID)
 	if err != nil {
@@ -56,8 +313,10 @@ func (s *ReminderService) LoadReminders(userID uint) error {
 }
 
 func (s *ReminderService) RemoveReminder(reminderID uint) {
-	if entryID, exists := s.entries[reminderID]; exists {
-		s.cron.Remove(entryID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if scheduled, exists := s.entries[reminderID]; exists {
+		s.cron.Remove(scheduled.entryID)
 		delete(s.entries, reminderID)
 	}
 }
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"github.com/robfig/cron/v3"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openTestDB connects to the database in TEST_DATABASE_URL, skipping the test when it is not set.
+// Every call opens its own pool, like a separate instance of the app would.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// countingBus records published events instead of delivering them
+type countingBus struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (b *countingBus) Publish(userID uint, event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, event)
+}
+
+func (b *countingBus) Subscribe(userID uint) (<-chan Event, func()) {
+	return make(chan Event), func() {}
+}
+
+func (b *countingBus) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.events)
+}
+
+// Several schedulers share one database and fire the same occurrence at once, as replicas do.
+// The occurrence must be delivered exactly once.
+func TestReminderFiresOnceAcrossSchedulers(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&models.User{}, &models.Reminder{}, &models.ReminderLog{}); err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.NewUserRepository(db)
+	user := models.User{FullName: "reminder test", Password: "x"}
+	if err := repo.Create(&user); err != nil {
+		t.Fatal(err)
+	}
+	reminder := models.Reminder{UserID: user.ID, Type: "meal", Time: "0 19 * * *", Message: "Dinner", Active: true}
+	if err := repo.CreateReminder(&reminder); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where("reminder_id = ?", reminder.ID).Delete(&models.ReminderLog{})
+		db.Delete(&reminder)
+		db.Delete(&user)
+	})
+
+	const schedulers = 5
+	bus := &countingBus{}
+	due := time.Date(2026, 1, 1, 19, 0, 0, 0, time.UTC)
+	for round := 0; round < 2; round++ {
+		var wg sync.WaitGroup
+		for i := 0; i < schedulers; i++ {
+			svc := NewReminderService(repository.NewUserRepository(openTestDB(t)), bus)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				svc.fire(&reminder, due.AddDate(0, 0, round))
+			}()
+		}
+		wg.Wait()
+	}
+
+	if n := bus.count(); n != 2 {
+		t.Fatalf("delivered %d times, want once per occurrence (2)", n)
+	}
+	var logs []models.ReminderLog
+	if err := db.Where("reminder_id = ?", reminder.ID).Order("scheduled_at").Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("got %d log rows, want 2", len(logs))
+	}
+	for _, l := range logs {
+		if l.Status != "sent" {
+			t.Errorf("occurrence %s has status %q, want sent", l.ScheduledAt, l.Status)
+		}
+	}
+}
+
+// Every scheduler runs its own cron and works out the due time of an occurrence on its own.
+// They must still agree on it, so each occurrence is delivered exactly once.
+func TestReminderFiresOnceThroughCron(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&models.User{}, &models.Reminder{}, &models.ReminderLog{}); err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.NewUserRepository(db)
+	user := models.User{FullName: "reminder cron test", Password: "x"}
+	if err := repo.Create(&user); err != nil {
+		t.Fatal(err)
+	}
+	reminder := models.Reminder{UserID: user.ID, Type: "meal", Time: "0 19 * * *", Message: "Dinner", Active: true}
+	if err := repo.CreateReminder(&reminder); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where("reminder_id = ?", reminder.ID).Delete(&models.ReminderLog{})
+		db.Delete(&reminder)
+		db.Delete(&user)
+	})
+
+	// Two occurrences off the whole second, which a clock-based due time could not reproduce
+	start := time.Now().Truncate(time.Second)
+	sched := fixedTimes{start.Add(1300 * time.Millisecond), start.Add(2300 * time.Millisecond)}
+	bus := &countingBus{}
+	for i := 0; i < 5; i++ {
+		svc := NewReminderService(repository.NewUserRepository(openTestDB(t)), bus)
+		svc.schedule(&reminder, sched, "test")
+		svc.cron.Start()
+		defer svc.cron.Stop()
+	}
+	time.Sleep(time.Until(sched[1]) + 2*time.Second)
+
+	if n := bus.count(); n != 2 {
+		t.Fatalf("delivered %d times, want once per occurrence (2)", n)
+	}
+	var logs []models.ReminderLog
+	if err := db.Where("reminder_id = ?", reminder.ID).Order("scheduled_at").Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("got %d log rows, want 2", len(logs))
+	}
+	for i, l := range logs {
+		if !l.ScheduledAt.Equal(sched[i]) {
+			t.Errorf("occurrence %d claimed as %s, want %s", i, l.ScheduledAt, sched[i])
+		}
+	}
+}
+
+// The claim is keyed on the scheduled time taken from the cron entry, not on the clock
+func TestReminderDueTimeFromCronEntry(t *testing.T) {
+	svc := NewReminderService(nil, &countingBus{})
+	svc.cron.Start()
+	defer svc.cron.Stop()
+
+	got := make(chan time.Time, 1)
+	sched := everySecondAt{offset: 300 * time.Millisecond}
+	entryID := svc.cron.Schedule(sched, cron.FuncJob(func() {}))
+	svc.mu.Lock()
+	svc.entries[1] = scheduledReminder{entryID: entryID, spec: "test"}
+	svc.mu.Unlock()
+	svc.cron.Schedule(sched, cron.FuncJob(func() {
+		select {
+		case got <- svc.dueTime(1):
+		default:
+		}
+	}))
+
+	select {
+	case due := <-got:
+		// The clock fallback is on a whole minute; only the cron entry knows the offset
+		if due.Nanosecond() != int(sched.offset) {
+			t.Fatalf("due time %s is not the scheduled time", due)
+		}
+		if prev := svc.cron.Entry(entryID).Prev; !due.Equal(prev) {
+			t.Fatalf("due time %s, entry ran at %s", due, prev)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("entry never ran")
+	}
+}
+
+// everySecondAt fires every second, offset past the whole second
+type everySecondAt struct {
+	offset time.Duration
+}
+
+func (s everySecondAt) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Second).Add(s.offset)
+	if !next.After(t) {
+		next = next.Add(time.Second)
+	}
+	return next
+}
+
+// fixedTimes fires at the listed times only
+type fixedTimes []time.Time
+
+func (s fixedTimes) Next(t time.Time) time.Time {
+	for _, at := range s {
+		if at.After(t) {
+			return at
+		}
+	}
+	return time.Time{}
+}
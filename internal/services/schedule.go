@@ -0,0 +1,130 @@
+package services
+
+import (
+	"diplomIshi/internal/models"
+	"errors"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxPreviewRuns = 20
+
+// CompileSchedule converts a human-friendly schedule into a standard cron spec.
+func CompileSchedule(schedule *models.ReminderSchedule) (string, error) {
+	if schedule == nil {
+		return "", errors.New("schedule is required")
+	}
+
+	hour, minute, err := parseTimeOfDay(schedule.TimeOfDay)
+	if err != nil {
+		return "", err
+	}
+
+	if schedule.IntervalHours < 0 || schedule.IntervalHours > 23 {
+		return "", errors.New("interval_hours must be between 0 and 23")
+	}
+
+	if schedule.EndDate != nil && schedule.EndDate.Before(time.Now()) {
+		return "", errors.New("end_date must be in the future")
+	}
+
+	hours := []string{strconv.Itoa(hour)}
+	if schedule.IntervalHours > 0 {
+		for h := hour + schedule.IntervalHours; h < 24; h += schedule.IntervalHours {
+			hours = append(hours, strconv.Itoa(h))
+		}
+	}
+
+	days := "*"
+	if len(schedule.DaysOfWeek) > 0 {
+		seen := make(map[int]bool)
+		var list []int
+		for _, d := range schedule.DaysOfWeek {
+			if d < 0 || d > 6 {
+				return "", fmt.Errorf("invalid day of week %d, expected 0 (Sunday) to 6 (Saturday)", d)
+			}
+			if !seen[d] {
+				seen[d] = true
+				list = append(list, d)
+			}
+		}
+		sort.Ints(list)
+		parts := make([]string, len(list))
+		for i, d := range list {
+			parts[i] = strconv.Itoa(d)
+		}
+		days = strings.Join(parts, ",")
+	}
+
+	return fmt.Sprintf("%d %s * * %s", minute, strings.Join(hours, ","), days), nil
+}
+
+// cronSpec is the reminder's spec in its user's time zone, so "0 8 * * *" fires at 08:00 on their clock
+func cronSpec(reminder *models.Reminder) string {
+	if _, err := time.LoadLocation(reminder.Timezone); reminder.Timezone == "" || err != nil {
+		return reminder.Time
+	}
+	return "CRON_TZ=" + reminder.Timezone + " " + reminder.Time
+}
+
+// ParseReminderSchedule builds the cron schedule for a reminder, honouring its end date and time zone.
+func ParseReminderSchedule(reminder *models.Reminder) (cron.Schedule, error) {
+	if strings.Contains(reminder.Time, "TZ=") {
+		return nil, fmt.Errorf("invalid schedule %q: reminders run in the user's time zone", reminder.Time)
+	}
+	sched, err := cron.ParseStandard(cronSpec(reminder))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", reminder.Time, err)
+	}
+	if reminder.Schedule != nil && reminder.Schedule.EndDate != nil {
+		return endDateSchedule{Schedule: sched, end: *reminder.Schedule.EndDate}, nil
+	}
+	return sched, nil
+}
+
+// NextRuns returns up to n fire times of the schedule after from.
+func NextRuns(schedule cron.Schedule, from time.Time, n int) []time.Time {
+	if n <= 0 {
+		n = 1
+	}
+	if n > maxPreviewRuns {
+		n = maxPreviewRuns
+	}
+
+	runs := []time.Time{}
+	next := from
+	for len(runs) < n {
+		next = schedule.Next(next)
+		if next.IsZero() {
+			break
+		}
+		runs = append(runs, next)
+	}
+	return runs
+}
+
+func parseTimeOfDay(value string) (int, int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time_of_day %q, expected HH:MM", value)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// endDateSchedule stops firing once the end date has passed.
+type endDateSchedule struct {
+	cron.Schedule
+	end time.Time
+}
+
+func (s endDateSchedule) Next(t time.Time) time.Time {
+	next := s.Schedule.Next(t)
+	if next.After(s.end) {
+		return time.Time{} // cron never runs a zero time
+	}
+	return next
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"diplomIshi/internal/models"
+	"diplomIshi/internal/repository"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math"
+	"time"
+)
+
+const freezeTokenCost = 100 // Points spent on one streak freeze
+
+var (
+	ErrUnknownStreakType = errors.New("unknown streak type")
+	ErrNotEnoughPoints   = errors.New("not enough points")
+)
+
+// Rest days each streak type tolerates between two active days
+var streakRestDays = map[string]int{
+	"workout":        1,
+	"food_log":       0,
+	"calorie_target": 0,
+	"water":          0,
+}
+
+var streakTypes = []string{"workout", "food_log", "calorie_target", "water"}
+
+type StreakService struct {
+	repo    *repository.UserRepository
+	calcSvc *CalculatorService
+}
+
+func NewStreakService(repo *repository.UserRepository, calcSvc *CalculatorService) *StreakService {
+	return &StreakService{repo: repo, calcSvc: calcSvc}
+}
+
+// RecordActivity counts the day of at (in the user's time zone) towards the streak
+func (s *StreakService) RecordActivity(userID uint, streakType string, at time.Time) (*models.Streak, error) {
+	day, err := s.UserDay(userID, at)
+	if err != nil {
+		return nil, err
+	}
+	return s.RecordDay(userID, streakType, day)
+}
+
+// RecordDay counts a calendar day (as returned by UserDay) towards the streak.
+// Missed days beyond the rest allowance are covered by freeze tokens if there are enough,
+// otherwise the streak starts again from 1.
+func (s *StreakService) RecordDay(userID uint, streakType string, day time.Time) (*models.Streak, error) {
+	if _, ok := streakRestDays[streakType]; !ok {
+		return nil, ErrUnknownStreakType
+	}
+
+	var streak models.Streak
+	err := s.repo.Db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(models.Streak{UserID: userID, Type: streakType}).
+			FirstOrCreate(&streak).Error
+		if err != nil {
+			return err
+		}
+
+		backfilled := streak.LastActiveDate != nil && !day.After(*streak.LastActiveDate)
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.StreakDay{UserID: userID, Type: streakType, Date: day, Backfilled: backfilled})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 || backfilled {
+			return nil // Day already counted, or only shown in the calendar
+		}
+
+		switch {
+		case streak.LastActiveDate == nil:
+			streak.Current = 1
+		default:
+			missed := daysBetween(*streak.LastActiveDate, day) - 1 - streakRestDays[streakType]
+			if missed <= 0 {
+				streak.Current++
+			} else if streak.FreezeTokens >= missed {
+				streak.FreezeTokens -= missed
+				for i := 1; i <= missed; i++ {
+					frozen := models.StreakDay{UserID: userID, Type: streakType, Date: day.AddDate(0, 0, -i), Frozen: true}
+					if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&frozen).Error; err != nil {
+						return err
+					}
+				}
+				streak.Current++
+			} else {
+				streak.Current = 1
+			}
+		}
+
+		streak.LastActiveDate = &day
+		streak.Longest = max(streak.Longest, streak.Current)
+		return tx.Save(&streak).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &streak, nil
+}
+
+// RecordCalories counts calories logged at the given time towards the food log streak,
+// and towards the calorie target streak when they are within 10% of the user's recommended intake
+func (s *StreakService) RecordCalories(user *models.User, calories int, at time.Time) error {
+	if calories <= 0 {
+		return nil
+	}
+	if _, err := s.RecordActivity(user.ID, "food_log", at); err != nil {
+		return err
+	}
+	bmr := s.calcSvc.CalculateBMR(user.Weight, user.Height, user.Age, user.Gender)
+	target := s.calcSvc.RecommendCalories(s.calcSvc.CalculateTDEE(bmr, user.ActivityLevel), user.Goal)
+	if math.Abs(float64(calories-target)) > float64(target)*0.1 {
+		return nil
+	}
+	_, err := s.RecordActivity(user.ID, "calorie_target", at)
+	return err
+}
+
+// UndoCompletion reverses the completion and its points. Unless something else still counts towards
+// the streak on that day, the streak day is removed and the streak rebuilt, all in one transaction.
+func (s *StreakService) UndoCompletion(completion *models.Completion) error {
+	streakType := "food_log"
+	if completion.ItemType == "exercise" {
+		streakType = "workout"
+	}
+
+	return s.repo.Db.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.DeleteCompletionTx(tx, completion); err != nil {
+			return err
+		}
+
+		var streaks []models.Streak
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND type = ?", completion.UserID, streakType).Limit(1).Find(&streaks).Error
+		if err != nil || len(streaks) == 0 {
+			return err
+		}
+		streak := &streaks[0]
+
+		day := completion.Date.Format("2006-01-02")
+		var remaining int64
+		err = tx.Model(&models.Completion{}).
+			Where("user_id = ? AND item_type = ? AND date = ?::date", completion.UserID, completion.ItemType, day).
+			Count(&remaining).Error
+		if err != nil || remaining > 0 {
+			return err
+		}
+		if streakType == "food_log" {
+			// Calories logged with progress count towards the food log as well
+			err = tx.Model(&models.Progress{}).
+				Where("user_id = ? AND calories > 0 AND date::date = ?::date", completion.UserID, day).
+				Count(&remaining).Error
+			if err != nil || remaining > 0 {
+				return err
+			}
+		}
+
+		result := tx.Where("user_id = ? AND type = ? AND date = ?::date AND frozen = ?", completion.UserID, streakType, day, false).
+			Delete(&models.StreakDay{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		// Freeze tokens spent to reach the removed day are given back
+		var frozen []models.StreakDay
+		err = tx.Where("user_id = ? AND type = ? AND frozen = ? AND date < ?::date", completion.UserID, streakType, true, day).
+			Order("date desc").Find(&frozen).Error
+		if err != nil {
+			return err
+		}
+		bridged := 0
+		for next := completion.Date; bridged < len(frozen) && daysBetween(frozen[bridged].Date, next) == 1; bridged++ {
+			next = frozen[bridged].Date
+		}
+		if bridged > 0 {
+			if err := tx.Delete(frozen[:bridged]).Error; err != nil {
+				return err
+			}
+			streak.FreezeTokens += bridged
+		}
+		var days []models.StreakDay
+		err = tx.Where("user_id = ? AND type = ?", completion.UserID, streakType).Order("date asc").Find(&days).Error
+		if err != nil {
+			return err
+		}
+		rebuildStreak(streak, days, streakRestDays[streakType])
+		return tx.Save(streak).Error
+	})
+}
+
+// rebuildStreak recounts the streak from its days, sorted by date. Frozen days bridge a gap
+// without counting themselves and backfilled days are left out, like in RecordDay.
+func rebuildStreak(streak *models.Streak, days []models.StreakDay, restDays int) {
+	streak.Current, streak.Longest, streak.LastActiveDate = 0, 0, nil
+	var previous *time.Time
+	for i := range days {
+		if days[i].Backfilled {
+			continue
+		}
+		day := days[i].Date
+		if previous != nil && daysBetween(*previous, day)-1 > restDays {
+			streak.Current = 0
+		}
+		previous = &day
+		if days[i].Frozen {
+			continue
+		}
+		streak.Current++
+		streak.Longest = max(streak.Longest, streak.Current)
+		streak.LastActiveDate = &day
+	}
+	if streak.LastActiveDate == nil {
+		streak.Current = 0
+	}
+}
+
+// Streaks returns every streak type of the user. A streak that can no longer be
+// continued, even with freeze tokens, is reported with a current value of 0.
+func (s *StreakService) Streaks(userID uint) ([]models.Streak, error) {
+	stored, err := s.repo.GetStreaks(userID)
+	if err != nil {
+		return nil, err
+	}
+	today, err := s.UserDay(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	byType := make(map[string]models.Streak, len(stored))
+	for _, streak := range stored {
+		byType[streak.Type] = streak
+	}
+
+	streaks := make([]models.Streak, 0, len(streakTypes))
+	for _, streakType := range streakTypes {
+		streak, ok := byType[streakType]
+		if !ok {
+			streak = models.Streak{UserID: userID, Type: streakType}
+		}
+		if streak.LastActiveDate != nil {
+			// Today isn't over yet, so it doesn't count as missed
+			missed := daysBetween(*streak.LastActiveDate, today) - 1 - streakRestDays[streakType]
+			if missed > 0 && missed > streak.FreezeTokens {
+				streak.Current = 0
+			}
+		}
+		streaks = append(streaks, streak)
+	}
+	return streaks, nil
+}
+
+// Calendar returns the active days of the streak between from and to
+func (s *StreakService) Calendar(userID uint, streakType string, from, to time.Time) ([]models.StreakDay, error) {
+	if _, ok := streakRestDays[streakType]; !ok {
+		return nil, ErrUnknownStreakType
+	}
+	return s.repo.GetStreakDays(userID, streakType, from, to)
+}
+
+// BuyFreeze spends points on a freeze token for the streak. Spent points come out of the balance, not the XP.
+func (s *StreakService) BuyFreeze(userID uint, streakType string) (*models.Streak, error) {
+	if _, ok := streakRestDays[streakType]; !ok {
+		return nil, ErrUnknownStreakType
+	}
+
+	var streak models.Streak
+	err := s.repo.Db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(models.Streak{UserID: userID, Type: streakType}).
+			FirstOrCreate(&streak).Error
+		if err != nil {
+			return err
+		}
+
+		paid, err := s.repo.SpendPointsTx(tx, userID, freezeTokenCost)
+		if err != nil {
+			return err
+		}
+		if !paid {
+			return ErrNotEnoughPoints
+		}
+		streak.FreezeTokens++
+		return tx.Save(&streak).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &streak, nil
+}
+
+// UserDay converts a moment into the calendar day of the user's time zone, as midnight UTC
+func (s *StreakService) UserDay(userID uint, at time.Time) (time.Time, error) {
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := at.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
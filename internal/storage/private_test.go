@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func signedQuery(t *testing.T, s *LocalPrivateStorage, key string, expiry time.Duration) url.Values {
+	t.Helper()
+	signed, err := s.SignedURL(context.Background(), key, expiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/private/"+key {
+		t.Errorf("signed URL path is %s", u.Path)
+	}
+	return u.Query()
+}
+
+func TestLocalPrivateStorageSignedURL(t *testing.T) {
+	s, err := NewLocalPrivateStorage(t.TempDir(), "/private", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewLocalPrivateStorage(t.TempDir(), "/private", []byte("another secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "photos/originals/1/a.jpg"
+	q := signedQuery(t, s, key, time.Minute)
+	expired := signedQuery(t, s, key, -time.Second)
+
+	tests := []struct {
+		name                    string
+		key, expires, signature string
+		want                    bool
+	}{
+		{"valid", key, q.Get("expires"), q.Get("signature"), true},
+		{"other key", "photos/originals/2/a.jpg", q.Get("expires"), q.Get("signature"), false},
+		{"extended expiry", key, "9999999999", q.Get("signature"), false},
+		{"tampered signature", key, q.Get("expires"), q.Get("signature")[1:] + "0", false},
+		{"missing signature", key, q.Get("expires"), "", false},
+		{"invalid expiry", key, "soon", q.Get("signature"), false},
+		{"expired", key, expired.Get("expires"), expired.Get("signature"), false},
+	}
+	for _, tt := range tests {
+		if got := s.Verify(tt.key, tt.expires, tt.signature); got != tt.want {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if other.Verify(key, q.Get("expires"), q.Get("signature")) {
+		t.Error("a signature made with another secret was accepted")
+	}
+}
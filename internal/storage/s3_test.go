@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/minio/minio-go/v7"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// openTestS3 connects to the S3-compatible server in TEST_S3_ENDPOINT, e.g. a local MinIO on
+// "localhost:9000", creating the bucket when needed. The test is skipped when it is not set.
+func openTestS3(t *testing.T) *S3Storage {
+	t.Helper()
+	endpoint := os.Getenv("TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("TEST_S3_ENDPOINT is not set")
+	}
+	bucket := os.Getenv("TEST_S3_BUCKET")
+	if bucket == "" {
+		bucket = "diplom-test"
+	}
+	s, err := NewS3Storage(S3Config{
+		Endpoint:  endpoint,
+		Region:    "us-east-1",
+		Bucket:    bucket,
+		AccessKey: os.Getenv("TEST_S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("TEST_S3_SECRET_KEY"),
+		UseSSL:    os.Getenv("TEST_S3_USE_SSL") == "true",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	exists, err := s.client.BucketExists(ctx, bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		if err := s.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: "us-east-1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestS3StorageRoundTrip(t *testing.T) {
+	s := openTestS3(t)
+	ctx := context.Background()
+	key := "tests/" + time.Now().Format("20060102150405.000000000") + ".txt"
+	data := []byte("hello storage")
+
+	if err := s.Put(ctx, key, data, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Delete(context.Background(), key) })
+
+	r, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("Get returned %q, want %q", got, data)
+	}
+
+	signed, err := s.SignedURL(ctx, key, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(got) != string(data) {
+		t.Errorf("signed URL returned %d %q", resp.StatusCode, got)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete returned %v, want ErrNotFound", err)
+	}
+	// Deleting a missing key is not an error, like in LocalStorage
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("second Delete returned %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrNotFound = errors.New("file not found")
+
+// Storage keeps uploaded files. Keys are slash-separated paths such as "posts/12/a1b2.jpg".
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	PutReader(ctx context.Context, key string, r io.Reader, size int64, contentType string) error // Streams size bytes from r
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	URL(key string) string // Public URL of the file
+}
+
+// PrivateStorage keeps files that must not be publicly readable, such as progress photos.
+// They are only reachable through signed URLs that expire.
+type PrivateStorage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	PutReader(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}